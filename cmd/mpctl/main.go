@@ -0,0 +1,223 @@
+// mpctl — служебные команды маркетплейса (запускать из корня репо, рядом с .env)
+//
+//	mpctl gc-uploads [-grace 24h] [-history 2160h] [-dry-run]
+//	mpctl rates list
+//	mpctl rates set USD RUB 92.5
+//	mpctl rates sync -file rates.json
+//	mpctl categories list
+//	mpctl categories add "Books"
+//	mpctl users role alice admin
+//	mpctl escrow freeze 42 7 "buyer says the parcel is empty"
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	mydb "marketplace/internal/db"
+	"marketplace/internal/fx"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+	"marketplace/internal/orders"
+	"marketplace/internal/uploads"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(db *gorm.DB, args []string) error
+}
+
+var commands = []command{
+	{"gc-uploads", "remove uploaded files not referenced by any product", gcUploads},
+	{"rates", "list, set or sync exchange rates (list | set BASE QUOTE RATE | sync -file F)", rates},
+	{"categories", "list or add product categories (list | add NAME)", categories},
+	{"users", "change a user's role, e.g. grant moderation (role USERNAME buyer|seller|admin)", users},
+	{"escrow", "hold or release a seller's proceeds for an order (freeze|unfreeze ORDER SELLER [REASON])", escrow},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mpctl <command> [flags]")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	_ = godotenv.Overload(".env", "../.env", "../../.env")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			db := mydb.MustOpen()
+			if err := c.run(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	usage()
+}
+
+func gcUploads(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	grace := fs.Duration("grace", uploads.DefaultGrace, "keep files younger than this")
+	history := fs.Duration("history", uploads.DefaultHistory, "keep images of product revisions younger than this (0 = all)")
+	dir := fs.String("dir", uploads.Dir, "uploads directory")
+	dry := fs.Bool("dry-run", false, "only report, do not delete")
+	_ = fs.Parse(args)
+
+	gc := uploads.Collector{DB: db, Dir: *dir, Grace: *grace, History: *history, DryRun: *dry}
+	rep, err := gc.Run()
+	if err != nil {
+		return err
+	}
+	for _, name := range rep.Removed {
+		fmt.Println(name)
+	}
+	if *dry {
+		fmt.Print("dry run: ")
+	}
+	fmt.Println(rep)
+	return nil
+}
+
+func rates(db *gorm.DB, args []string) error {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "list":
+		var rows []models.ExchangeRate
+		if err := db.Order("base, quote").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			fmt.Printf("%s/%s\t%v\t%s\t%s\n", r.Base, r.Quote, r.Rate, r.Source, r.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	case "set":
+		if len(args) != 4 {
+			return fmt.Errorf("usage: mpctl rates set BASE QUOTE RATE")
+		}
+		base, quote := money.Currency(args[1]), money.Currency(args[2])
+		if !base.Known() || !quote.Known() {
+			return fmt.Errorf("unknown currency, supported: %v", money.Supported)
+		}
+		rate, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return err
+		}
+		return fx.Set(db, base, quote, rate, "manual")
+	case "sync":
+		fs := flag.NewFlagSet("rates sync", flag.ExitOnError)
+		file := fs.String("file", "", "JSON file {\"base\":\"USD\",\"rates\":{\"RUB\":92.5}}")
+		_ = fs.Parse(args[1:])
+		if *file == "" {
+			return fmt.Errorf("-file is required")
+		}
+		n, err := fx.Sync(context.Background(), db, fx.FileProvider{Path: *file})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("synced %d rates\n", n)
+		return nil
+	}
+	return fmt.Errorf("usage: mpctl rates list | set BASE QUOTE RATE | sync -file F")
+}
+
+func categories(db *gorm.DB, args []string) error {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "list":
+		var rows []models.Category
+		if err := db.Order("name").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, c := range rows {
+			fmt.Printf("%d\t%s\n", c.ID, c.Name)
+		}
+		return nil
+	case "add":
+		if len(args) != 2 || strings.TrimSpace(args[1]) == "" {
+			return fmt.Errorf("usage: mpctl categories add NAME")
+		}
+		c := models.Category{Name: strings.TrimSpace(args[1])}
+		if err := db.Create(&c).Error; err != nil {
+			return err
+		}
+		fmt.Printf("%d\t%s\n", c.ID, c.Name)
+		return nil
+	}
+	return fmt.Errorf("usage: mpctl categories list | add NAME")
+}
+
+func users(db *gorm.DB, args []string) error {
+	if len(args) != 3 || args[0] != "role" {
+		return fmt.Errorf("usage: mpctl users role USERNAME buyer|seller|admin")
+	}
+	role := models.Role(args[2])
+	if role != models.RoleBuyer && role != models.RoleSeller && role != models.RoleAdmin {
+		return fmt.Errorf("unknown role %q", args[2])
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var u models.User
+		if err := tx.Where("username = ?", args[1]).First(&u).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("user %q not found", args[1])
+			}
+			return err
+		}
+		if err := tx.Model(&u).Update("role", role).Error; err != nil {
+			return err
+		}
+		if role == models.RoleBuyer {
+			return nil
+		}
+		// роль не делает продавца проверенным: заводим пустую анкету, её заполняют и отправляют модератору
+		draft := models.SellerVerification{SellerID: u.ID, Status: models.VerificationDraft}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&draft).Error
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", args[1], role)
+	return nil
+}
+
+// escrow — ручная заморозка выручки по части заказа, пока спор решается вне сайта
+func escrow(db *gorm.DB, args []string) error {
+	if len(args) < 3 || (args[0] != "freeze" && args[0] != "unfreeze") {
+		return fmt.Errorf("usage: mpctl escrow freeze|unfreeze ORDER_ID SELLER_ID [REASON]")
+	}
+	orderID, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	sellerID, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if args[0] == "unfreeze" {
+			return orders.Unfreeze(tx, uint(orderID), uint(sellerID))
+		}
+		return orders.Freeze(tx, uint(orderID), uint(sellerID), strings.Join(args[3:], " "))
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// Ключи API продавца: создание (ключ показывается один раз) и отзыв
+func registerAPIKeyRoutes(r *gin.Engine, db *gorm.DB) {
+	page := func(c *gin.Context, status int, data ViewData) {
+		u := c.MustGet("currentUser").(*models.User)
+		var keys []models.APIKey
+		_ = db.Where("seller_id = ?", u.ID).Order("id desc").Find(&keys).Error
+		data["Keys"] = keys
+		data["Scopes"] = models.APIScopes
+		c.HTML(status, "api_keys.tmpl", withUser(c, data))
+	}
+
+	r.GET("/seller/api-keys", mustSeller(db), func(c *gin.Context) {
+		page(c, http.StatusOK, ViewData{})
+	})
+
+	r.POST("/seller/api-keys", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		name := strings.TrimSpace(c.PostForm("name"))
+		var scopes []models.APIScope
+		for _, s := range models.APIScopes {
+			if c.PostForm("scope_"+string(s)) != "" {
+				scopes = append(scopes, s)
+			}
+		}
+		if name == "" || len(scopes) == 0 {
+			page(c, http.StatusBadRequest, ViewData{"Error": "Name the key and choose at least one scope"})
+			return
+		}
+		key, plain, err := models.NewAPIKey(u.ID, name, scopes)
+		if err == nil {
+			err = db.Create(&key).Error
+		}
+		if err != nil {
+			page(c, http.StatusInternalServerError, ViewData{"Error": err.Error()})
+			return
+		}
+		page(c, http.StatusOK, ViewData{"NewKey": plain, "NewKeyName": key.Name})
+	})
+
+	r.POST("/seller/api-keys/:id/revoke", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		res := db.Model(&models.APIKey{}).
+			Where("id = ? AND seller_id = ? AND revoked_at IS NULL", c.Param("id"), u.ID).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			c.String(http.StatusInternalServerError, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/api-keys")
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/bulk"
+	models "marketplace/internal/models"
+)
+
+// categories — справочник категорий для выбора (пустой, если не загрузился)
+func categories(db *gorm.DB) []models.Category {
+	var list []models.Category
+	_ = db.Order("name").Find(&list).Error
+	return list
+}
+
+// Массовые действия в списке товаров: выбор → предпросмотр → применение → отмена в течение bulk.UndoWindow
+func registerBulkRoutes(r *gin.Engine, db *gorm.DB) {
+	preview := func(c *gin.Context, status int, ids []uint, action bulk.Action, value, errMsg string) {
+		u := c.MustGet("currentUser").(*models.User)
+		rows, err := bulk.Plan(db, u.ID, ids, action, value)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		apply := 0
+		for _, row := range rows {
+			if row.Error == "" && len(row.Changes) > 0 {
+				apply++
+			}
+		}
+		c.HTML(status, "bulk_preview.tmpl", withUser(c, ViewData{
+			"Rows": rows, "Action": action, "Value": value, "Apply": apply, "Error": errMsg,
+		}))
+	}
+
+	// Preview
+	r.POST("/seller/products/bulk", mustSeller(db), func(c *gin.Context) {
+		var ids []uint
+		for _, s := range c.PostFormArray("ids") {
+			if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+				ids = append(ids, uint(id))
+			}
+		}
+		action, value := bulk.Action(c.PostForm("action")), c.PostForm("value")
+		if action == bulk.SetCategory {
+			value = c.PostForm("category")
+		}
+		preview(c, http.StatusOK, ids, action, value, "")
+	})
+
+	// Apply — версии из предпросмотра: если товары с тех пор меняли, показываем предпросмотр заново
+	r.POST("/seller/products/bulk/apply", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		action, value := bulk.Action(c.PostForm("action")), c.PostForm("value")
+		versions := map[uint]int{}
+		var ids []uint
+		for _, s := range c.PostFormArray("ids") {
+			id, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				continue
+			}
+			v, _ := strconv.Atoi(c.PostForm(fmt.Sprintf("v_%d", id)))
+			versions[uint(id)] = v
+			ids = append(ids, uint(id))
+		}
+		edit, err := bulk.Apply(db, u.ID, versions, action, value)
+		if errors.Is(err, bulk.ErrChanged) {
+			preview(c, http.StatusConflict, ids, action, value, err.Error()+". Review the new preview and apply again.")
+			return
+		}
+		if err != nil {
+			preview(c, http.StatusBadRequest, ids, action, value, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/seller/products?bulk=%d", edit.ID))
+	})
+
+	r.POST("/seller/products/bulk/:id/undo", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		restored, skipped, err := bulk.Undo(db, u.ID, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		if err != nil {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/seller/products?undone=%d&skipped=%d", restored, skipped))
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/analytics"
+	models "marketplace/internal/models"
+)
+
+// периоды дашборда, дней
+var dashboardPeriods = []int{7, 30, 90}
+
+// Дашборд продавца: выручка, заказы, конверсия, топ товаров и заканчивающиеся остатки
+func registerDashboardRoutes(r *gin.Engine, db *gorm.DB) {
+	r.GET("/seller/dashboard", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		days, _ := strconv.Atoi(c.Query("period"))
+		valid := false
+		for _, d := range dashboardPeriods {
+			valid = valid || d == days
+		}
+		if !valid {
+			days = 30
+		}
+		from := time.Now().AddDate(0, 0, -days)
+
+		summary, err := analytics.SellerSummary(db, u.ID, from)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		daily, err := analytics.Daily(db, u.ID, from)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		top, err := analytics.TopProducts(db, u.ID, from, 10)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		low, err := analytics.LowStock(db, u.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// столбики дневного графика в процентах от лучшего дня
+		type bar struct {
+			analytics.DaySales
+			Pct int64
+		}
+		var maxUnits int64 = 1
+		for _, d := range daily {
+			maxUnits = max(maxUnits, d.Units)
+		}
+		bars := make([]bar, len(daily))
+		for i, d := range daily {
+			bars[i] = bar{DaySales: d, Pct: d.Units * 100 / maxUnits}
+		}
+		c.HTML(http.StatusOK, "dashboard.tmpl", withUser(c, ViewData{
+			"Period": days, "Periods": dashboardPeriods,
+			"Summary": summary, "Daily": bars,
+			"Top": top, "LowStock": low, "LowStockThreshold": analytics.LowStockThreshold,
+		}))
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/disputes"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// disputeRole — кем пользователь приходится спору: buyer, seller, admin; "" — посторонний
+func disputeRole(d *models.Dispute, u *models.User) string {
+	switch {
+	case u.ID == d.BuyerID:
+		return "buyer"
+	case u.ID == d.SellerID:
+		return "seller"
+	case u.Role == models.RoleAdmin:
+		return "admin"
+	}
+	return ""
+}
+
+// disputeError — ошибка действия, которую можно показать пользователю; иначе 500
+func disputeError(err error) (int, bool) {
+	switch {
+	case errors.Is(err, disputes.ErrInvalid), errors.Is(err, disputes.ErrAmount),
+		errors.Is(err, disputes.ErrWindow), errors.Is(err, disputes.ErrOpen), errors.Is(err, disputes.ErrNoSale):
+		return http.StatusUnprocessableEntity, true
+	case errors.Is(err, disputes.ErrState):
+		return http.StatusConflict, true
+	}
+	return http.StatusInternalServerError, false
+}
+
+// buyerOrderItem — позиция :item заказа :id покупателя u; при ошибке пишет 404 сам
+func buyerOrderItem(c *gin.Context, db *gorm.DB, u *models.User) (*models.Order, *models.OrderItem, bool) {
+	var order models.Order
+	if err := db.First(&order, "id = ? AND buyer_id = ?", c.Param("id"), u.ID).Error; err != nil {
+		c.String(http.StatusNotFound, "Not found")
+		return nil, nil, false
+	}
+	var item models.OrderItem
+	if err := db.First(&item, "id = ? AND order_id = ?", c.Param("item"), order.ID).Error; err != nil {
+		c.String(http.StatusNotFound, "Not found")
+		return nil, nil, false
+	}
+	return &order, &item, true
+}
+
+// Возвраты и споры: заявка покупателя, переписка, ответ продавца и решение площадки
+func registerDisputeRoutes(r *gin.Engine, db *gorm.DB) {
+	form := func(c *gin.Context, status int, order *models.Order, item *models.OrderItem, errMsg string) {
+		c.HTML(status, "dispute_new.tmpl", withUser(c, ViewData{
+			"Order": order, "Item": item, "Reasons": models.DisputeReasons, "MaxPhotos": disputes.MaxPhotos,
+			"Error": errMsg, "Reason": c.PostForm("reason"), "Body": c.PostForm("body"),
+		}))
+	}
+
+	r.GET("/orders/:id/items/:item/dispute", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		if order, item, ok := buyerOrderItem(c, db, u); ok {
+			form(c, http.StatusOK, order, item, "")
+		}
+	})
+
+	r.POST("/orders/:id/items/:item/dispute", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		order, item, ok := buyerOrderItem(c, db, u)
+		if !ok {
+			return
+		}
+		photos, err := saveUploadedImages(c, "photos", disputes.MaxPhotos)
+		if err != nil {
+			form(c, http.StatusBadRequest, order, item, err.Error())
+			return
+		}
+		qty, _ := strconv.Atoi(c.PostForm("qty"))
+		d, err := disputes.Open(db, u.ID, *item, disputes.Input{
+			Reason: models.DisputeReason(c.PostForm("reason")), Qty: qty, Body: c.PostForm("body"), Photos: photos,
+		})
+		if err != nil {
+			status, _ := disputeError(err)
+			form(c, status, order, item, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/disputes/"+strconv.Itoa(int(d.ID)))
+	})
+
+	// ------ списки ------
+	list := func(c *gin.Context, title string, q *gorm.DB, filter string) {
+		status := models.DisputeStatus(c.Query("status"))
+		if filter != "" && status == "" {
+			status = models.DisputeEscalated
+		}
+		if status != "" {
+			q = q.Where("status = ?", status)
+		}
+		var items []models.Dispute
+		if err := q.Preload("Item").Order("updated_at desc").Limit(200).Find(&items).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		var ids []uint
+		for _, d := range items {
+			ids = append(ids, d.BuyerID, d.SellerID)
+		}
+		c.HTML(http.StatusOK, "disputes.tmpl", withUser(c, ViewData{
+			"Title": title, "List": items, "Names": usernames(db, ids),
+			"Filter": filter, "Status": status, "Statuses": models.DisputeStatuses,
+		}))
+	}
+
+	r.GET("/disputes", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		list(c, "My returns and disputes", db.Where("buyer_id = ?", u.ID), "")
+	})
+
+	r.GET("/seller/disputes", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		list(c, "Returns and disputes", db.Where("seller_id = ?", u.ID), "")
+	})
+
+	r.GET("/admin/disputes", mustAdmin(db), func(c *gin.Context) {
+		list(c, "Disputes", db, "/admin/disputes")
+	})
+
+	// ------ спор ------
+	// current — спор из URL и роль пользователя в нём; ошибку пишет сам
+	current := func(c *gin.Context) (*models.Dispute, *models.User, string, bool) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return nil, nil, "", false
+		}
+		var d models.Dispute
+		err = db.Preload("Item").
+			Preload("Messages", func(q *gorm.DB) *gorm.DB { return q.Order("id") }).
+			Preload("Messages.Photos").
+			First(&d, "id = ?", c.Param("id")).Error
+		if err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return nil, nil, "", false
+		}
+		role := disputeRole(&d, u)
+		if role == "" {
+			c.String(http.StatusNotFound, "Not found")
+			return nil, nil, "", false
+		}
+		return &d, u, role, true
+	}
+	show := func(c *gin.Context, status int, d *models.Dispute, role, errMsg string) {
+		var order models.Order
+		_ = db.Select("id", "currency", "buyer_id").First(&order, d.OrderID).Error
+		names := usernames(db, []uint{d.BuyerID, d.SellerID})
+		for _, m := range d.Messages {
+			if m.AuthorID != 0 && names[m.AuthorID] == "" {
+				names[m.AuthorID] = "marketplace"
+			}
+		}
+		c.HTML(status, "dispute.tmpl", withUser(c, ViewData{
+			"D": d, "Role": role, "Names": names, "Currency": order.Currency,
+			"Refundable": money.New(d.Item.UnitAmount*int64(d.Qty), order.Currency), "MaxPhotos": disputes.MaxPhotos, "Error": errMsg,
+		}))
+	}
+	// act — действие над спором: при ошибке страница спора с сообщением, иначе обратно на неё
+	act := func(c *gin.Context, roles string, fn func(d *models.Dispute, u *models.User) error) {
+		d, u, role, ok := current(c)
+		if !ok {
+			return
+		}
+		if !strings.Contains(roles, role) {
+			c.String(http.StatusForbidden, "Forbidden")
+			return
+		}
+		if err := fn(d, u); err != nil {
+			status, shown := disputeError(err)
+			if !shown {
+				c.String(status, err.Error())
+				return
+			}
+			show(c, status, d, role, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/disputes/"+c.Param("id"))
+	}
+
+	r.GET("/disputes/:id", mustLogin(), func(c *gin.Context) {
+		if d, _, role, ok := current(c); ok {
+			show(c, http.StatusOK, d, role, "")
+		}
+	})
+
+	r.POST("/disputes/:id/messages", mustLogin(), func(c *gin.Context) {
+		act(c, "buyer seller admin", func(d *models.Dispute, u *models.User) error {
+			photos, err := saveUploadedImages(c, "photos", disputes.MaxPhotos)
+			if err != nil {
+				return fmt.Errorf("%w: %v", disputes.ErrInvalid, err)
+			}
+			return disputes.Say(db, d, u.ID, c.PostForm("body"), photos)
+		})
+	})
+
+	r.POST("/disputes/:id/accept", mustLogin(), func(c *gin.Context) {
+		act(c, "seller", func(d *models.Dispute, u *models.User) error {
+			return disputes.Accept(db, d.ID, u.ID, c.PostForm("return") == "1")
+		})
+	})
+
+	r.POST("/disputes/:id/reject", mustLogin(), func(c *gin.Context) {
+		act(c, "seller", func(d *models.Dispute, u *models.User) error {
+			return disputes.Reject(db, d.ID, u.ID, c.PostForm("reason"))
+		})
+	})
+
+	r.POST("/disputes/:id/return", mustLogin(), func(c *gin.Context) {
+		act(c, "buyer", func(d *models.Dispute, u *models.User) error {
+			carrier, tracking := strings.TrimSpace(c.PostForm("carrier")), strings.TrimSpace(c.PostForm("tracking_number"))
+			if tracking == "" {
+				return fmt.Errorf("%w: enter the tracking number", disputes.ErrInvalid)
+			}
+			return disputes.ShipReturn(db, d.ID, u.ID, carrier, tracking)
+		})
+	})
+
+	r.POST("/disputes/:id/received", mustLogin(), func(c *gin.Context) {
+		act(c, "seller", func(d *models.Dispute, u *models.User) error {
+			return disputes.ReceiveReturn(db, d.ID, u.ID)
+		})
+	})
+
+	r.POST("/disputes/:id/withdraw", mustLogin(), func(c *gin.Context) {
+		act(c, "buyer", func(d *models.Dispute, u *models.User) error {
+			return disputes.Withdraw(db, d.ID, u.ID)
+		})
+	})
+
+	// Resolve — решение площадки: возврат (полный или частичный, с товаром на склад или без) или отказ
+	r.POST("/disputes/:id/resolve", mustAdmin(db), func(c *gin.Context) {
+		act(c, "admin", func(d *models.Dispute, u *models.User) error {
+			dec := disputes.Decision{
+				Refund: c.PostForm("decision") == "refund", Restock: c.PostForm("restock") == "1", Note: c.PostForm("note"),
+			}
+			if s := strings.TrimSpace(c.PostForm("amount")); dec.Refund && s != "" {
+				var order models.Order
+				if err := db.Select("currency").First(&order, d.OrderID).Error; err != nil {
+					return err
+				}
+				m, err := money.Parse(s, order.Currency)
+				if err != nil {
+					return fmt.Errorf("%w: %v", disputes.ErrInvalid, err)
+				}
+				dec.Amount = m.Amount
+			}
+			return disputes.Resolve(db, d.ID, u.ID, dec)
+		})
+	})
+}
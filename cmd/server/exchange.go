@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/commerceml"
+	models "marketplace/internal/models"
+)
+
+// exchangeReply — ответ 1С: строки текста, первая — success/failure/progress
+func exchangeReply(c *gin.Context, lines ...string) {
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(strings.Join(lines, "\n")))
+}
+
+// exchangeSeller — продавец из сессии (cookie от checkauth) или из Basic-авторизации
+func exchangeSeller(c *gin.Context, db *gorm.DB) (*models.User, bool) {
+	if u, err := sessionUser(c, db); err == nil && u.Role == models.RoleSeller {
+		return u, true
+	}
+	login, pw, ok := c.Request.BasicAuth()
+	if ok && login != "" {
+		var u models.User
+		err := db.Where("username = ? OR email = ?", login, login).First(&u).Error
+		if err == nil && u.Role == models.RoleSeller && models.CheckPassword(u.PasswordHash, pw) {
+			return &u, true
+		}
+	}
+	exchangeReply(c, "failure", "authorization required")
+	return nil, false
+}
+
+// exchangeFile — путь к файлу обмена внутри папки продавца; ".." и абсолютные пути не пускаем
+func exchangeFile(sellerID uint, name string) (string, error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." || filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+		return "", fmt.Errorf("bad filename")
+	}
+	return filepath.Join(commerceml.Dir, fmt.Sprint(sellerID), name), nil
+}
+
+// exchangeAppend дописывает часть файла: большой файл приходит несколькими запросами.
+// Часть, с которой файл или весь сеанс в dir превысили бы предел, не записывается.
+func exchangeAppend(name, dir string, body io.Reader) error {
+	var session int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil {
+			session += info.Size()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	room := min(commerceml.MaxFileSize-size, commerceml.MaxSessionSize-session)
+	n, err := io.Copy(f, io.LimitReader(body, max(room, 0)+1))
+	if err == nil && n > room {
+		err = fmt.Errorf("file is larger than %d MB or the exchange is larger than %d MB",
+			commerceml.MaxFileSize>>20, commerceml.MaxSessionSize>>20)
+	}
+	if err != nil {
+		// недописанную часть убираем: 1С пришлёт её заново или начнёт сеанс с init
+		_ = f.Truncate(size)
+		return err
+	}
+	return f.Close()
+}
+
+// Обмен с 1С по CommerceML 2: 1С сама ходит сюда по расписанию.
+// type=catalog: checkauth → init → file (частями) → import; type=sale: checkauth → init → query → success.
+func registerExchangeRoutes(r *gin.Engine, db *gorm.DB) {
+	priceType := os.Getenv("EXCHANGE_PRICE_TYPE")
+
+	handle := func(c *gin.Context) {
+		u, ok := exchangeSeller(c, db)
+		if !ok {
+			return
+		}
+		kind, mode := c.Query("type"), c.Query("mode")
+		switch mode {
+		case "checkauth":
+			sess := sessions.Default(c)
+			sess.Set("user_email", u.Email)
+			sess.Set("user_username", u.Username)
+			if err := sess.Save(); err != nil {
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			// 1С присылает эту cookie в следующих запросах
+			for _, raw := range c.Writer.Header().Values("Set-Cookie") {
+				if ck, err := http.ParseSetCookie(raw); err == nil && ck.Name == sessionCookie {
+					exchangeReply(c, "success", ck.Name, ck.Value)
+					return
+				}
+			}
+			exchangeReply(c, "failure", "no session")
+
+		case "init":
+			if kind == "catalog" {
+				// новый сеанс выгрузки: файлы прошлого больше не нужны
+				dir := filepath.Join(commerceml.Dir, fmt.Sprint(u.ID))
+				if err := os.RemoveAll(dir); err != nil {
+					exchangeReply(c, "failure", err.Error())
+					return
+				}
+			}
+			exchangeReply(c, "zip=no", fmt.Sprintf("file_limit=%d", commerceml.FileLimit))
+
+		case "file":
+			body := http.MaxBytesReader(c.Writer, c.Request.Body, commerceml.FileLimit)
+			if kind != "catalog" {
+				// изменения заказов со стороны 1С пока не принимаем
+				_, _ = io.Copy(io.Discard, body)
+				exchangeReply(c, "success")
+				return
+			}
+			name, err := exchangeFile(u.ID, c.Query("filename"))
+			if err == nil {
+				err = os.MkdirAll(filepath.Dir(name), 0o700)
+			}
+			if err == nil {
+				err = exchangeAppend(name, filepath.Join(commerceml.Dir, fmt.Sprint(u.ID)), body)
+			}
+			if err != nil {
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			exchangeReply(c, "success")
+
+		case "import":
+			name, err := exchangeFile(u.ID, c.Query("filename"))
+			if err != nil {
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			// картинки (import_files/...) и прочее не разбираем
+			if kind != "catalog" || !strings.EqualFold(filepath.Ext(name), ".xml") {
+				exchangeReply(c, "success")
+				return
+			}
+			res, err := commerceml.ImportFile(db, name, commerceml.Options{SellerID: u.ID, PriceType: priceType})
+			if err != nil {
+				log.Printf("1c exchange seller %d %s: %v", u.ID, filepath.Base(name), err)
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			log.Printf("1c exchange seller %d %s: %s", u.ID, filepath.Base(name), res)
+			exchangeReply(c, "success")
+
+		case "query":
+			after, err := commerceml.Pending(db, u.ID)
+			var buf bytes.Buffer
+			var last uint
+			if err == nil {
+				last, err = commerceml.WriteOrders(&buf, db, u.ID, after)
+			}
+			if err == nil {
+				err = commerceml.Sent(db, u.ID, last)
+			}
+			if err != nil {
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			c.Data(http.StatusOK, "application/xml; charset=utf-8", buf.Bytes())
+
+		case "success":
+			if err := commerceml.Ack(db, u.ID); err != nil {
+				exchangeReply(c, "failure", err.Error())
+				return
+			}
+			exchangeReply(c, "success")
+
+		case "deactivate", "complete":
+			// режимы 1С 8.3: товары, не попавшие в полную выгрузку, не снимаем
+			exchangeReply(c, "success")
+
+		default:
+			exchangeReply(c, "failure", "unknown mode")
+		}
+	}
+	r.GET("/1c/exchange", handle)
+	r.POST("/1c/exchange", handle)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/importer"
+	models "marketplace/internal/models"
+)
+
+// baseURL — адрес сайта для абсолютных ссылок: PUBLIC_URL или из запроса
+func baseURL(c *gin.Context) string {
+	if u := os.Getenv("PUBLIC_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// Выгрузка товаров продавца. Формат совпадает с импортом: файл можно поправить и загрузить обратно.
+func registerExportRoutes(r *gin.Engine, db *gorm.DB) {
+	r.GET("/seller/products/export", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		format := c.DefaultQuery("format", "csv")
+		export, contentType := importer.ExportCSV, "text/csv; charset=utf-8"
+		switch format {
+		case "csv":
+		case "json":
+			export, contentType = importer.ExportJSON, "application/json; charset=utf-8"
+		default:
+			c.String(http.StatusBadRequest, "Unknown format, use csv or json")
+			return
+		}
+		name := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102"), format)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		c.Status(http.StatusOK)
+		// заголовки уже ушли — ошибку можно только залогировать
+		if err := export(c.Writer, db, u.ID, baseURL(c)); err != nil {
+			log.Printf("export seller %d: %v", u.ID, err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/feed"
+	models "marketplace/internal/models"
+)
+
+// siteName — название площадки в фидах
+const siteName = "Marketplace"
+
+// Фиды для Яндекс Маркета и Google Merchant Center: всего сайта и отдельного продавца
+func registerFeedRoutes(r *gin.Engine, db *gorm.DB) {
+	cache := feed.NewCache(db, feed.Dir)
+	// адрес сайта в фиде — только из PUBLIC_URL: с адресом из заголовка Host
+	// любой запрос пересобирал бы фид со своими ссылками
+	public := strings.TrimRight(os.Getenv("PUBLIC_URL"), "/")
+	if public == "" {
+		log.Printf("feeds: PUBLIC_URL is not set, feeds are disabled")
+	}
+
+	serve := func(c *gin.Context, format feed.Format, shop feed.Shop) {
+		if public == "" {
+			c.String(http.StatusServiceUnavailable, "Feeds are unavailable: PUBLIC_URL is not set")
+			return
+		}
+		shop.BaseURL = public
+		name, err := cache.File(format, shop)
+		if err != nil {
+			log.Printf("feed %s seller %d: %v", format, shop.SellerID, err)
+			c.String(http.StatusInternalServerError, "Feed is unavailable")
+			return
+		}
+		c.Header("Content-Type", "application/xml; charset=utf-8")
+		c.File(name)
+	}
+	site := func(format feed.Format) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			serve(c, format, feed.Shop{Name: siteName})
+		}
+	}
+	seller := func(format feed.Format) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+			if err != nil {
+				c.String(http.StatusNotFound, "Not found")
+				return
+			}
+			var u models.User
+			if err := db.First(&u, "id = ? AND role = ?", id, models.RoleSeller).Error; err != nil {
+				c.String(http.StatusNotFound, "Not found")
+				return
+			}
+			name := u.Username
+			if shop := shopsFor(db, []uint{u.ID})[u.ID]; shop != nil {
+				name = shop.ShopName
+			}
+			serve(c, format, feed.Shop{Name: name, SellerID: u.ID})
+		}
+	}
+
+	r.GET("/feeds/yml.xml", site(feed.YML))
+	r.GET("/feeds/google.xml", site(feed.Google))
+	r.GET("/feeds/sellers/:id/yml.xml", seller(feed.YML))
+	r.GET("/feeds/sellers/:id/google.xml", seller(feed.Google))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/importer"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// ownImportJob — задание импорта текущего продавца; 404 пишет сам
+func ownImportJob(c *gin.Context, db *gorm.DB) (*models.ImportJob, bool) {
+	u := c.MustGet("currentUser").(*models.User)
+	var job models.ImportJob
+	if err := db.First(&job, "id = ? AND seller_id = ?", c.Param("id"), u.ID).Error; err != nil {
+		c.String(http.StatusNotFound, "Not found")
+		return nil, false
+	}
+	return &job, true
+}
+
+// importJobPage — шаг сопоставления колонок / отчёт; Mapping с -1 для несопоставленных полей
+func importJobPage(c *gin.Context, status int, job *models.ImportJob, errMsg string) {
+	mapping := map[string]int{}
+	for _, f := range importer.Fields {
+		mapping[f.Name] = -1
+		if i, ok := job.Mapping[f.Name]; ok {
+			mapping[f.Name] = i
+		}
+	}
+	c.HTML(status, "import_job.tmpl", withUser(c, ViewData{
+		"Job":     job,
+		"Fields":  importer.Fields,
+		"Mapping": mapping,
+		"Error":   errMsg,
+	}))
+}
+
+// Массовый импорт: загрузка файла → сопоставление колонок → dry-run → применение в фоне
+func registerImportRoutes(r *gin.Engine, db *gorm.DB) {
+	importList := func(c *gin.Context, status int, errMsg string) {
+		u := c.MustGet("currentUser").(*models.User)
+		var list []models.ImportJob
+		_ = db.Where("seller_id = ?", u.ID).Order("id desc").Limit(20).Find(&list).Error
+		c.HTML(status, "import.tmpl", withUser(c, ViewData{"Jobs": list, "Error": errMsg}))
+	}
+
+	r.GET("/seller/import", mustSeller(db), func(c *gin.Context) {
+		importList(c, http.StatusOK, "")
+	})
+
+	// Upload
+	r.POST("/seller/import", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		file, err := c.FormFile("file")
+		if err != nil {
+			importList(c, http.StatusBadRequest, "Choose a file")
+			return
+		}
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		if ext != ".csv" && ext != ".xlsx" && ext != ".json" {
+			importList(c, http.StatusBadRequest, "Unsupported file type, use .csv, .xlsx or .json")
+			return
+		}
+		if file.Size > importer.MaxFileSize {
+			importList(c, http.StatusBadRequest, fmt.Sprintf("File is larger than %d MB", importer.MaxFileSize>>20))
+			return
+		}
+		_ = os.MkdirAll(importer.Dir, 0o700)
+		dst := filepath.Join(importer.Dir, fmt.Sprintf("%d-%d%s", u.ID, time.Now().UnixNano(), ext))
+		if err := c.SaveUploadedFile(file, dst); err != nil {
+			importList(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rows, err := importer.ReadFile(dst)
+		if err == nil && len(rows) < 2 {
+			err = fmt.Errorf("file has no data rows")
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+			importList(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		job := models.ImportJob{
+			SellerID: u.ID,
+			FileName: file.Filename,
+			Path:     dst,
+			Status:   models.ImportUploaded,
+			Header:   rows[0],
+			Rows:     len(rows) - 1,
+			Mapping:  importer.GuessMapping(rows[0]),
+			Currency: money.RUB,
+		}
+		if err := db.Create(&job).Error; err != nil {
+			_ = os.Remove(dst)
+			importList(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/seller/import/%d", job.ID))
+	})
+
+	r.GET("/seller/import/:id", mustSeller(db), func(c *gin.Context) {
+		job, ok := ownImportJob(c, db)
+		if !ok {
+			return
+		}
+		importJobPage(c, http.StatusOK, job, "")
+	})
+
+	// Preview — сохранить сопоставление колонок и сделать dry-run
+	r.POST("/seller/import/:id/preview", mustSeller(db), func(c *gin.Context) {
+		job, ok := ownImportJob(c, db)
+		if !ok {
+			return
+		}
+		if job.Status != models.ImportUploaded && job.Status != models.ImportPreviewed {
+			c.String(http.StatusConflict, "Import is already %s", job.Status)
+			return
+		}
+		job.Mapping = map[string]int{}
+		for _, f := range importer.Fields {
+			if i, err := strconv.Atoi(c.PostForm("map_" + f.Name)); err == nil && i >= 0 && i < len(job.Header) {
+				job.Mapping[f.Name] = i
+			}
+		}
+		if cur := money.Currency(c.PostForm("currency")); cur.Known() {
+			job.Currency = cur
+		}
+		rep, err := importer.Preview(db, job)
+		if err != nil {
+			importJobPage(c, http.StatusBadRequest, job, err.Error())
+			return
+		}
+		job.Report, job.Status = rep, models.ImportPreviewed
+		if err := db.Model(job).Select("mapping", "currency", "report", "status").Updates(job).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/seller/import/%d", job.ID))
+	})
+
+	// Apply — поставить в очередь фоновой обработки (importer.RunQueued)
+	r.POST("/seller/import/:id/apply", mustSeller(db), func(c *gin.Context) {
+		job, ok := ownImportJob(c, db)
+		if !ok {
+			return
+		}
+		res := db.Model(job).Where("status = ?", models.ImportPreviewed).Update("status", models.ImportQueued)
+		if res.Error != nil {
+			c.String(http.StatusInternalServerError, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.String(http.StatusConflict, "Run the preview first")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/seller/import/%d", job.ID))
+	})
+}
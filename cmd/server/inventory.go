@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// inventoryPageSize — сколько последних движений показываем
+const inventoryPageSize = 200
+
+// stockByWarehouse — остаток товара на складе (для страницы остатков)
+type stockByWarehouse struct {
+	models.Warehouse
+	Qty int
+}
+
+// Журнал движения остатка товара, остатки по складам, ручная корректировка с причиной и перемещение
+func registerInventoryRoutes(r *gin.Engine, db *gorm.DB) {
+	page := func(c *gin.Context, status int, item *models.Product, errMsg string) {
+		var moves []models.InventoryMovement
+		if err := db.Where("product_id = ?", item.ID).Order("id desc").Limit(inventoryPageSize).Find(&moves).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		var levels []stockByWarehouse
+		err := db.Model(&models.Warehouse{}).
+			Select("warehouses.*, COALESCE(stock_levels.qty, 0) AS qty").
+			Joins("LEFT JOIN stock_levels ON stock_levels.warehouse_id = warehouses.id AND stock_levels.product_id = ?", item.ID).
+			Where("warehouses.seller_id = ?", item.SellerID).
+			Order("warehouses.priority, warehouses.id").
+			Scan(&levels).Error
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		names := map[uint]string{}
+		for _, l := range levels {
+			names[l.ID] = l.Name
+		}
+		warehouseOf := map[uint]string{} // движение → склад
+		for _, m := range moves {
+			if m.WarehouseID != nil {
+				warehouseOf[m.ID] = names[*m.WarehouseID]
+			}
+		}
+		// имена тех, кто менял остаток (покупателей не показываем — у продаж есть номер заказа)
+		ids := make([]uint, 0, len(moves))
+		for _, m := range moves {
+			if m.Reason != models.MoveSale {
+				ids = append(ids, m.ActorID)
+			}
+		}
+		var users []models.User
+		_ = db.Where("id IN ?", ids).Find(&users).Error
+		actors := map[uint]string{0: "system"}
+		for _, u := range users {
+			actors[u.ID] = u.Username
+		}
+		c.HTML(status, "inventory.tmpl", withUser(c, ViewData{
+			"Item": item, "Moves": moves, "Actors": actors, "Reasons": models.AdjustReasons, "Error": errMsg,
+			"Levels": levels, "WarehouseOf": warehouseOf,
+		}))
+	}
+
+	r.GET("/seller/products/:id/inventory", mustSeller(db), func(c *gin.Context) {
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		page(c, http.StatusOK, item, "")
+	})
+
+	inventoryURL := func(item *models.Product) string {
+		return "/seller/products/" + strconv.FormatUint(uint64(item.ID), 10) + "/inventory"
+	}
+
+	// Adjust — приход, пересчёт, порча, потеря на выбранном складе: delta со знаком
+	r.POST("/seller/products/:id/inventory", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		reason := models.MovementReason(c.PostForm("reason"))
+		delta, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(c.PostForm("delta")), "+"))
+		if err != nil || delta == 0 {
+			page(c, http.StatusBadRequest, item, "Enter a non-zero whole number, e.g. 10 or -2")
+			return
+		}
+		if !slices.Contains(models.AdjustReasons, reason) {
+			page(c, http.StatusBadRequest, item, "Choose a reason")
+			return
+		}
+		warehouse, err := strconv.ParseUint(c.PostForm("warehouse_id"), 10, 64)
+		if err != nil {
+			page(c, http.StatusBadRequest, item, "Choose a warehouse")
+			return
+		}
+		note := strings.TrimSpace(c.PostForm("note"))
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return models.AdjustStock(tx, item, uint(warehouse), delta, reason, u.ID, note)
+		})
+		if !stockError(c, page, item, err) {
+			return
+		}
+		c.Redirect(http.StatusSeeOther, inventoryURL(item))
+	})
+
+	// Transfer — перемещение между складами: общий остаток и версия товара не меняются
+	r.POST("/seller/products/:id/inventory/transfer", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		from, err1 := strconv.ParseUint(c.PostForm("from"), 10, 64)
+		to, err2 := strconv.ParseUint(c.PostForm("to"), 10, 64)
+		qty, err3 := strconv.Atoi(strings.TrimSpace(c.PostForm("qty")))
+		if err1 != nil || err2 != nil || err3 != nil || qty <= 0 || from == to {
+			page(c, http.StatusBadRequest, item, "Choose two different warehouses and a positive quantity")
+			return
+		}
+		note := strings.TrimSpace(c.PostForm("note"))
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return models.TransferStock(tx, *item, uint(from), uint(to), qty, u.ID, note)
+		})
+		if !stockError(c, page, item, err) {
+			return
+		}
+		c.Redirect(http.StatusSeeOther, inventoryURL(item))
+	})
+}
+
+// stockError показывает страницу с ошибкой изменения остатка; false — ошибка была
+func stockError(c *gin.Context, page func(*gin.Context, int, *models.Product, string), item *models.Product, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrInsufficientStock):
+		page(c, http.StatusBadRequest, item, "Not enough stock in that warehouse")
+	case errors.Is(err, models.ErrWarehouse):
+		page(c, http.StatusBadRequest, item, "Choose one of your warehouses")
+	default:
+		page(c, http.StatusInternalServerError, item, err.Error())
+	}
+	return false
+}
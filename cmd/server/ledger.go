@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/ledger"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// ledgerRow — проводка по счёту продавца вместе с операцией
+type ledgerRow struct {
+	CreatedAt time.Time
+	Kind      models.LedgerKind
+	Memo      string
+	OrderID   *uint
+	Account   models.LedgerAccount
+	Amount    int64 // для продавца: + пришло на счёт, − ушло
+	Currency  money.Currency
+}
+
+// Money — сумма для шаблона
+func (r ledgerRow) Money() money.Money { return money.New(r.Amount, r.Currency) }
+
+// ruleRow — правило комиссии с именами продавца и категории
+type ruleRow struct {
+	models.CommissionRule
+	Seller, Category string // пусто — для всех
+}
+
+// ledgerTotal — оборот площадки по счёту в валюте
+type ledgerTotal struct {
+	Account  models.LedgerAccount
+	Currency money.Currency
+	Sum      int64
+}
+
+// Money — сумма для шаблона; комиссия — кредитовый счёт, её остаток показываем положительным
+func (t ledgerTotal) Money() money.Money {
+	if t.Account == models.AccountCommission {
+		return money.New(-t.Sum, t.Currency)
+	}
+	return money.New(t.Sum, t.Currency)
+}
+
+// parseRate — ставка в процентах ("5", "7.5") в базисные пункты
+func parseRate(s string) (int, error) {
+	pct, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), 64)
+	if err != nil || pct < 0 || pct > 100 || math.IsNaN(pct) {
+		return 0, fmt.Errorf("rate must be a percent from 0 to 100, e.g. 5 or 7.5")
+	}
+	return int(math.Round(pct * 100)), nil
+}
+
+// Баланс продавца, правила комиссии и выплаты
+func registerLedgerRoutes(r *gin.Engine, db *gorm.DB, provider ledger.Provider, autoConfirm, hold time.Duration) {
+	r.GET("/seller/balance", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		balances, err := ledger.Balances(db, u.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		var rows []ledgerRow
+		err = db.Model(&models.LedgerPosting{}).
+			Select("t.created_at, t.kind, t.memo, t.order_id, ledger_postings.account, -ledger_postings.amount AS amount, ledger_postings.currency").
+			Joins("JOIN ledger_transactions t ON t.id = ledger_postings.transaction_id").
+			Where("ledger_postings.seller_id = ?", u.ID).
+			Order("ledger_postings.id desc").Limit(100).
+			Scan(&rows).Error
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		var payouts []models.Payout
+		_ = db.Where("seller_id = ?", u.ID).Order("id desc").Limit(50).Find(&payouts).Error
+		verified, _ := models.SellerVerified(db, u.ID)
+		noBank := false
+		if v, err := verificationOf(db, u.ID); err == nil && v != nil {
+			noBank = v.BankEditable()
+		}
+		c.HTML(http.StatusOK, "balance.tmpl", withUser(c, ViewData{
+			"Balances": balances, "Rows": rows, "Payouts": payouts, "Verified": verified, "NoBank": noBank,
+			"AutoConfirmDays": int(autoConfirm.Hours() / 24), "HoldDays": int(hold.Hours() / 24),
+		}))
+	})
+
+	// ------ комиссия ------
+	commissions := func(c *gin.Context, status int, errMsg string) {
+		var rules []models.CommissionRule
+		_ = db.Order("seller_id NULLS FIRST, category_id NULLS FIRST").Find(&rules).Error
+		sellers := map[uint]string{}
+		var users []models.User
+		_ = db.Where("id IN (SELECT seller_id FROM commission_rules)").Find(&users).Error
+		for _, u := range users {
+			sellers[u.ID] = u.Username
+		}
+		cats := categories(db)
+		names := map[uint]string{}
+		for _, cat := range cats {
+			names[cat.ID] = cat.Name
+		}
+		rows := make([]ruleRow, 0, len(rules))
+		for _, rule := range rules {
+			row := ruleRow{CommissionRule: rule}
+			if rule.SellerID != nil {
+				row.Seller = sellers[*rule.SellerID]
+			}
+			if rule.CategoryID != nil {
+				row.Category = names[*rule.CategoryID]
+			}
+			rows = append(rows, row)
+		}
+		c.HTML(status, "commissions.tmpl", withUser(c, ViewData{
+			"Rules": rows, "Categories": cats,
+			"Default": models.CommissionRule{RateBP: ledger.DefaultRateBP}.Percent(), "Error": errMsg,
+		}))
+	}
+
+	r.GET("/admin/commissions", mustAdmin(db), func(c *gin.Context) {
+		commissions(c, http.StatusOK, "")
+	})
+
+	// Add — правило для всех, категории, продавца или продавца в категории; то же сочетание перезаписывается
+	r.POST("/admin/commissions", mustAdmin(db), func(c *gin.Context) {
+		rate, err := parseRate(c.PostForm("rate"))
+		if err != nil {
+			commissions(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule := models.CommissionRule{RateBP: rate}
+		if name := strings.TrimSpace(c.PostForm("seller")); name != "" {
+			var u models.User
+			if err := db.First(&u, "username = ? AND role IN ?", name, []models.Role{models.RoleSeller, models.RoleAdmin}).Error; err != nil {
+				commissions(c, http.StatusBadRequest, "No seller named "+name)
+				return
+			}
+			rule.SellerID = &u.ID
+		}
+		if id, err := strconv.ParseUint(c.PostForm("category_id"), 10, 64); err == nil && id > 0 {
+			cat := uint(id)
+			rule.CategoryID = &cat
+		}
+		q := db.Model(&models.CommissionRule{})
+		if rule.SellerID != nil {
+			q = q.Where("seller_id = ?", *rule.SellerID)
+		} else {
+			q = q.Where("seller_id IS NULL")
+		}
+		if rule.CategoryID != nil {
+			q = q.Where("category_id = ?", *rule.CategoryID)
+		} else {
+			q = q.Where("category_id IS NULL")
+		}
+		res := q.Update("rate_bp", rate)
+		if res.Error == nil && res.RowsAffected == 0 {
+			res = db.Create(&rule)
+		}
+		if res.Error != nil {
+			commissions(c, http.StatusInternalServerError, res.Error.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/admin/commissions")
+	})
+
+	r.POST("/admin/commissions/:id/delete", mustAdmin(db), func(c *gin.Context) {
+		if err := db.Delete(&models.CommissionRule{}, "id = ?", c.Param("id")).Error; err != nil {
+			commissions(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/admin/commissions")
+	})
+
+	// ------ выплаты ------
+	payouts := func(c *gin.Context, status int, msg, errMsg string) {
+		var list []models.Payout
+		_ = db.Order("id desc").Limit(100).Find(&list).Error
+		sellers := map[uint]string{}
+		var users []models.User
+		_ = db.Where("id IN (SELECT seller_id FROM payouts)").Find(&users).Error
+		for _, u := range users {
+			sellers[u.ID] = u.Username
+		}
+		// доход площадки и деньги на её счёте по валютам
+		var totals []ledgerTotal
+		_ = db.Model(&models.LedgerPosting{}).
+			Select("account, currency, SUM(amount) AS sum").
+			Where("account IN ?", []models.LedgerAccount{models.AccountCash, models.AccountCommission}).
+			Group("account, currency").Order("account, currency").
+			Scan(&totals).Error
+		c.HTML(status, "payouts.tmpl", withUser(c, ViewData{
+			"Payouts": list, "Sellers": sellers, "Totals": totals, "Provider": provider.Name(), "Message": msg, "Error": errMsg,
+		}))
+	}
+
+	r.GET("/admin/payouts", mustAdmin(db), func(c *gin.Context) {
+		payouts(c, http.StatusOK, c.Query("done"), "")
+	})
+
+	// Run — внеочередной прогон выплат (обычно их делает фоновая задача payouts)
+	r.POST("/admin/payouts/run", mustAdmin(db), func(c *gin.Context) {
+		rep, err := ledger.RunPayouts(c.Request.Context(), db, provider)
+		if err != nil {
+			log.Println("payouts:", err)
+			payouts(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/admin/payouts?done="+rep.String())
+	})
+}
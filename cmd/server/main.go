@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,14 +21,33 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"marketplace/internal/analytics"
+	"marketplace/internal/bulk"
+	"marketplace/internal/catalog"
 	mydb "marketplace/internal/db"
+	"marketplace/internal/fx"
+	"marketplace/internal/importer"
+	"marketplace/internal/jobs"
+	"marketplace/internal/ledger"
 	models "marketplace/internal/models"
+	"marketplace/internal/money"
+	"marketplace/internal/notify"
+	"marketplace/internal/orders"
+	"marketplace/internal/payments"
+	"marketplace/internal/reviews"
+	"marketplace/internal/uploads"
 )
 
 type ViewData map[string]any
 
+// sessionCookie — имя cookie сессии (его же получает 1С при обмене)
+const sessionCookie = "mp_session"
+
 const cartKey = "cart" // map[string]int
 
+// currencyKey — валюта, в которой покупатель смотрит цены
+const currencyKey = "currency"
+
 func withUser(c *gin.Context, data ViewData) ViewData {
 	if data == nil {
 		data = ViewData{}
@@ -47,6 +70,14 @@ func withUser(c *gin.Context, data ViewData) ViewData {
 		}
 	}
 	data["CartCount"] = count
+
+	// валюта показа и курсы (ставит middleware в main)
+	if v, ok := c.Get("fx"); ok {
+		conv := v.(fx.Converter)
+		data["FX"] = conv
+		data["Currency"] = conv.To
+		data["Currencies"] = money.Supported
+	}
 	return data
 }
 
@@ -96,6 +127,58 @@ func mustSeller(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// mustAdmin — модерация (анкеты продавцов и т.п.); currentUser как у mustSeller
+func mustAdmin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			c.Abort()
+			return
+		}
+		if u.Role != models.RoleAdmin {
+			c.String(http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+		c.Set("currentUser", u)
+		c.Next()
+	}
+}
+
+// sessionUser — залогиненный пользователь по сессии (email или username)
+func sessionUser(c *gin.Context, db *gorm.DB) (*models.User, error) {
+	sess := sessions.Default(c)
+	email, _ := sess.Get("user_email").(string)
+	username, _ := sess.Get("user_username").(string)
+	var u models.User
+	q := db
+	if email != "" {
+		q = q.Where("email = ?", email)
+	} else {
+		q = q.Where("username = ?", username)
+	}
+	if err := q.First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ownProduct грузит товар продавца из currentUser (ставит mustSeller); 404/403 пишет сам.
+func ownProduct(c *gin.Context, db *gorm.DB, id string) (*models.Product, bool) {
+	u := c.MustGet("currentUser").(*models.User)
+	var item models.Product
+	if err := db.First(&item, "id = ?", id).Error; err != nil {
+		c.String(http.StatusNotFound, "Not found")
+		return nil, false
+	}
+	if item.SellerID != u.ID {
+		c.String(http.StatusForbidden, "Forbidden")
+		return nil, false
+	}
+	return &item, true
+}
+
 // ---------- uploads helper ----------
 func saveUploadedImage(c *gin.Context, field string) (string, error) {
 	file, err := c.FormFile(field)
@@ -103,17 +186,43 @@ func saveUploadedImage(c *gin.Context, field string) (string, error) {
 		// файл не выбран — не ошибка
 		return "", nil
 	}
+	return saveImage(c, file)
+}
+
+// saveUploadedImages — как saveUploadedImage, но для поля с несколькими файлами (не больше max)
+func saveUploadedImages(c *gin.Context, field string, max int) ([]string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil
+	}
+	files := form.File[field]
+	if len(files) > max {
+		return nil, fmt.Errorf("up to %d images", max)
+	}
+	var paths []string
+	for _, file := range files {
+		p, err := saveImage(c, file)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// saveImage сохраняет картинку в uploads и возвращает её URL
+func saveImage(c *gin.Context, file *multipart.FileHeader) (string, error) {
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".webp" {
 		return "", fmt.Errorf("unsupported image format")
 	}
-	_ = os.MkdirAll("uploads", 0o755) // <<< гарантируем наличие папки
+	_ = os.MkdirAll(uploads.Dir, 0o755) // <<< гарантируем наличие папки
 	name := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	dst := filepath.Join("uploads", name)
+	dst := filepath.Join(uploads.Dir, name)
 	if err := c.SaveUploadedFile(file, dst); err != nil {
 		return "", err
 	}
-	return "/uploads/" + name, nil
+	return uploads.URLPrefix + name, nil
 }
 
 // ---------- cart in sessions ----------
@@ -140,6 +249,42 @@ func fileExists(p string) bool {
 	return err == nil
 }
 
+// productInput — поля товара из формы seller_form.tmpl
+func productInput(c *gin.Context) catalog.Input {
+	return catalog.Input{
+		SKU:         c.PostForm("sku"),
+		Title:       c.PostForm("title"),
+		Description: c.PostForm("description"),
+		Price:       c.PostForm("price"),
+		Currency:    c.PostForm("currency"),
+		Stock:       c.PostForm("stock"),
+		Status:      c.PostForm("status"),
+		PublishAt:   c.PostForm("publish_at"),
+		LowStock:    c.PostForm("low_stock"),
+	}
+}
+
+// productForm — значения для повторного показа seller_form.tmpl; version нужна только при правке
+func productForm(in catalog.Input, version int) ViewData {
+	f := ViewData(in.Form())
+	f["Version"] = version
+	return f
+}
+
+// envDuration читает длительность из env ("6h", "30m"), при пустом/кривом значении — def
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("WARN: bad %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
 func main() {
 	// <<< грузим .env и из текущей папки, и из родительской (когда запускаем из cmd/server)
 	// грузим .env из нескольких мест: текущая папка, родительская, корень репо
@@ -156,17 +301,144 @@ func main() {
 
 
 	db := mydb.MustOpen()
-	if err := db.AutoMigrate(&models.User{}, &models.Product{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Product{}, &models.ProductRevision{},
+		&models.ExchangeRate{}, &models.Order{}, &models.OrderItem{}, &models.ProductViewStat{},
+		&models.ImportJob{},
+		&models.ExchangeState{},
+		&models.APIKey{},
+		&models.Category{},
+		&models.BulkEdit{},
+		&models.InventoryMovement{},
+		&models.Notification{},
+		&models.StockSubscription{},
+		&models.Warehouse{},
+		&models.StockLevel{},
+		&models.SellerProfile{},
+		&models.SellerVerification{},
+		&models.VerificationDocument{},
+		&models.LedgerTransaction{},
+		&models.LedgerPosting{},
+		&models.CommissionRule{},
+		&models.Payout{},
+		&models.Shipment{},
+		&models.Dispute{},
+		&models.DisputeMessage{},
+		&models.DisputePhoto{},
+		&models.Review{},
+		&models.ReviewPhoto{},
+		&models.SellerFeedback{},
+		&models.SellerRating{}); err != nil {
+		log.Fatal(err)
+	}
+	// товары, заведённые до журнала движения, получают начальный остаток
+	if err := models.OpenBalances(db); err != nil {
+		log.Fatal(err)
+	}
+	// и склад "Main" с этим остатком
+	if err := models.OpenWarehouses(db); err != nil {
+		log.Fatal(err)
+	}
+	// продавцы, заведённые до анкет, считаются проверенными (один раз, пока анкет нет)
+	if err := models.OpenVerifications(db); err != nil {
+		log.Fatal(err)
+	}
+	// заказы, оформленные до отправлений, получают их
+	if err := models.OpenShipments(db); err != nil {
+		log.Fatal(err)
+	}
+	// части заказов, полностью возвращённые до отправки, считаются отменёнными
+	if err := orders.OpenCancellations(db); err != nil {
 		log.Fatal(err)
 	}
 
 	sqlDB, _ := db.DB()
 	defer sqlDB.Close()
 
+	// фоновая чистка картинок, на которые больше никто не ссылается (то же делает `mpctl gc-uploads`)
+	gc := uploads.Collector{DB: db, Dir: uploads.Dir, Grace: envDuration("UPLOADS_GC_GRACE", uploads.DefaultGrace),
+		History: envDuration("UPLOADS_GC_HISTORY", uploads.DefaultHistory)}
+	go jobs.Every(context.Background(), "uploads-gc", envDuration("UPLOADS_GC_INTERVAL", 6*time.Hour), func(context.Context) error {
+		rep, err := gc.Run()
+		if err == nil && len(rep.Removed) > 0 {
+			log.Println("uploads-gc:", rep)
+		}
+		return err
+	})
+
+	// массовый импорт товаров: применяет файлы, которые продавцы отправили в очередь
+	go jobs.Every(context.Background(), "imports", envDuration("IMPORT_INTERVAL", 5*time.Second), func(context.Context) error {
+		return importer.RunQueued(db)
+	})
+
+	// уведомления из очереди: email, если настроен SMTP, иначе в лог
+	dispatcher := notify.Dispatcher{DB: db, Channel: notify.FromEnv(), BaseURL: os.Getenv("PUBLIC_URL")}
+	go jobs.Every(context.Background(), "notify", envDuration("NOTIFY_INTERVAL", 30*time.Second), dispatcher.Run)
+
+	// планировщик: публикует scheduled-товары, когда наступил PublishAt
+	go jobs.Every(context.Background(), "publish-scheduled", envDuration("PUBLISH_INTERVAL", time.Minute), func(context.Context) error {
+		n, err := models.PublishDue(db, time.Now())
+		if n > 0 {
+			log.Printf("publish-scheduled: published %d products", n)
+		}
+		return err
+	})
+
+	// отпуск продавцов: возвращает магазины, у которых наступила дата возвращения
+	go jobs.Every(context.Background(), "vacations", envDuration("VACATION_INTERVAL", time.Minute), func(context.Context) error {
+		n, err := models.EndVacations(db, time.Now())
+		if n > 0 {
+			log.Printf("vacations: %d shops are back", n)
+		}
+		return err
+	})
+
+	// деньги: выручка становится доступной через PAYOUT_HOLD после того, как покупатель получил заказ
+	// (или вышел срок после отправки), доступное выплачивается провайдером
+	payoutProvider, err := ledger.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// оплата заказов: продажа попадает в книгу только после списания денег у покупателя
+	paymentProvider, err := payments.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	autoConfirm := envDuration("ORDER_AUTO_CONFIRM", orders.DefaultAutoConfirm)
+	go jobs.Every(context.Background(), "auto-confirm", envDuration("AUTO_CONFIRM_INTERVAL", 10*time.Minute), func(context.Context) error {
+		n, err := orders.AutoConfirm(db, autoConfirm, time.Now())
+		if n > 0 {
+			log.Printf("auto-confirm: %d shipments delivered", n)
+		}
+		return err
+	})
+	// выручка по полученным заказам становится доступной, когда пройдёт удержание (срок возврата)
+	hold := envDuration("PAYOUT_HOLD", ledger.DefaultHold)
+	go jobs.Every(context.Background(), "ledger-release", envDuration("LEDGER_RELEASE_INTERVAL", 10*time.Minute), func(context.Context) error {
+		n, err := ledger.ReleaseDue(db, hold, time.Now())
+		if n > 0 {
+			log.Printf("ledger-release: %d sales released", n)
+		}
+		return err
+	})
+	go jobs.Every(context.Background(), "payouts", envDuration("PAYOUT_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+		rep, err := ledger.RunPayouts(ctx, db, payoutProvider)
+		if rep.Paid+rep.Failed > 0 {
+			log.Println("payouts:", rep)
+		}
+		return err
+	})
+
+	// репутация продавцов: оценки покупателей, отправка в срок, отмены
+	shipWithin := envDuration("SHIP_WITHIN", reviews.DefaultShipWithin)
+	go jobs.Every(context.Background(), "seller-ratings", envDuration("SELLER_RATING_INTERVAL", time.Hour), func(context.Context) error {
+		_, err := reviews.RecountSellers(db, shipWithin, time.Now())
+		return err
+	})
+
 	r := gin.Default()
 
 	// раздача статики
-	r.Static("/uploads", "./uploads")
+	r.Static("/uploads", "./"+uploads.Dir)
 	r.Static("/static", "./static")
 
 	// sessions
@@ -176,16 +448,40 @@ func main() {
 	}
 	store := cookie.NewStore([]byte(secret))
 	store.Options(sessions.Options{HttpOnly: true, SameSite: http.SameSiteLaxMode})
-	r.Use(sessions.Sessions("mp_session", store))
+	r.Use(sessions.Sessions(sessionCookie, store))
+
+	// курсы валют: валюта расчётов для оформления заказа и валюта показа из сессии
+	settlement := money.Currency(os.Getenv("SETTLEMENT_CURRENCY"))
+	if !settlement.Known() {
+		settlement = money.RUB
+	}
+	rates := fx.NewCache(db, time.Minute)
+	r.Use(func(c *gin.Context) {
+		display := settlement
+		if v, ok := sessions.Default(c).Get(currencyKey).(string); ok && money.Currency(v).Known() {
+			display = money.Currency(v)
+		}
+		c.Set("fx", fx.Converter{Table: rates.Table(), To: display})
+		c.Next()
+	})
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		go jobs.Every(context.Background(), "fx-sync", envDuration("FX_SYNC_INTERVAL", time.Hour), func(ctx context.Context) error {
+			_, err := fx.Sync(ctx, db, fx.FileProvider{Path: path})
+			return err
+		})
+	}
 
 	// templates
-	r.SetFuncMap(template.FuncMap{
-		"price": func(cents int) string { return fmt.Sprintf("%.2f", float64(cents)/100.0) },
-		"add":   func(a, b int) int { return a + b },
-		"sub":   func(a, b int) int { return a - b },
+	pages, err := loadPages("internal/views", template.FuncMap{
+		"money":   func(m money.Money) string { return m.String() },
+		"decimal": func(m money.Money) string { return m.Decimal() },
+		"add":     func(a, b int) int { return a + b },
+		"sub":     func(a, b int) int { return a - b },
 	})
-	// <<< рекурсивный паттерн, чтобы находить и internal/views/seller/*.tmpl тоже
-	r.LoadHTMLGlob("internal/views/**/*.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.HTMLRender = pages
 
 
 	// health
@@ -200,18 +496,44 @@ func main() {
 	// JSON
 	r.GET("/products", func(c *gin.Context) {
 		var items []models.Product
-		if err := db.Order("id desc").Find(&items).Error; err != nil {
+		if err := db.Scopes(models.ListedProducts).Order("id desc").Find(&items).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, items)
 	})
 
-	// Public index
+	// Public index (?sort=rating — по рейтингу)
 	r.GET("/", func(c *gin.Context) {
 		var items []models.Product
-		_ = db.Order("id desc").Find(&items).Error
-		c.HTML(http.StatusOK, "list.tmpl", withUser(c, ViewData{"Items": items}))
+		_ = db.Scopes(models.ListedProducts).Order(catalogOrder(c.Query("sort"))).Find(&items).Error
+		sellers := make([]uint, len(items))
+		for i, p := range items {
+			sellers[i] = p.SellerID
+		}
+		c.HTML(http.StatusOK, "list.tmpl", withUser(c, ViewData{"Items": items, "Shops": shopsFor(db, sellers), "Sort": c.Query("sort")}))
+	})
+
+	// Product page (считает просмотры для конверсии в дашборде продавца)
+	r.GET("/product/:id", func(c *gin.Context) {
+		var p models.Product
+		if err := db.Scopes(models.ListedProducts).First(&p, "id = ?", c.Param("id")).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		if err := analytics.RecordView(db, p.ID, time.Now()); err != nil {
+			log.Println("record view:", err)
+		}
+		data := ViewData{"Item": p, "OutOfStock": c.Query("out") != "", "Subscribed": c.Query("subscribed") != "",
+			"Shop": shopsFor(db, []uint{p.SellerID})[p.SellerID]}
+		data["Reviews"], data["ReviewAuthors"] = productReviews(db, p.ID)
+		// уже ждёт поступления — кнопку «Notify me» не показываем
+		if u, err := sessionUser(c, db); err == nil && p.Stock <= 0 {
+			var n int64
+			db.Model(&models.StockSubscription{}).Where("product_id = ? AND user_id = ? AND notified_at IS NULL", p.ID, u.ID).Count(&n)
+			data["Subscribed"] = n > 0
+		}
+		c.HTML(http.StatusOK, "show.tmpl", withUser(c, data))
 	})
 
 	// Register (email OR phone) + username/password
@@ -336,12 +658,8 @@ func main() {
 			c.Redirect(http.StatusSeeOther, "/seller/products")
 			return
 		}
-		u.Role = models.RoleSeller
-		if err := db.Save(&u).Error; err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-			return
-		}
-		c.Redirect(http.StatusSeeOther, "/seller/products")
+		// продавцом становятся, заполнив анкету (onboarding.go)
+		c.Redirect(http.StatusSeeOther, "/seller/onboarding")
 	})
 
 	// ------ Seller area ------
@@ -363,86 +681,84 @@ func main() {
 			return
 		}
 
+		// вкладки: активные / архив (архивные + удалённые, их можно восстановить)
+		tab := c.Query("tab")
+		q = db.Where("seller_id = ?", u.ID)
+		if tab == "archive" {
+			q = q.Unscoped().Scopes(models.ArchivedProducts)
+		} else {
+			tab = "active"
+			q = q.Scopes(models.ActiveProducts)
+		}
 		var items []models.Product
-		if err := db.Where("seller_id = ?", u.ID).Order("id desc").Find(&items).Error; err != nil {
+		if err := q.Order("id desc").Find(&items).Error; err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.HTML(http.StatusOK, "seller_products.tmpl", withUser(c, ViewData{"Items": items}))
+		data := ViewData{"Items": items, "Tab": tab, "Categories": categories(db)}
+		if v, err := verificationOf(db, u.ID); err == nil && (v == nil || v.Status != models.VerificationApproved) {
+			data["Verification"] = v
+			data["Unverified"] = true
+		}
+		// только что применённое массовое действие — показываем кнопку отмены
+		if id := c.Query("bulk"); id != "" {
+			var e models.BulkEdit
+			if db.First(&e, "id = ? AND seller_id = ?", id, u.ID).Error == nil && bulk.Undoable(e) {
+				data["Bulk"] = e
+			}
+		}
+		data["Undone"], data["Skipped"] = c.Query("undone"), c.Query("skipped")
+		c.HTML(http.StatusOK, "seller_products.tmpl", withUser(c, data))
 	})
 
 	// New form
 	r.GET("/seller/products/new", mustSeller(db), func(c *gin.Context) {
-		c.HTML(http.StatusOK, "seller_form.tmpl", withUser(c, ViewData{"Mode": "create"}))
+		u := c.MustGet("currentUser").(*models.User)
+		verified, _ := models.SellerVerified(db, u.ID)
+		c.HTML(http.StatusOK, "seller_form.tmpl", withUser(c, ViewData{"Mode": "create", "Unverified": !verified}))
 	})
 
 	// Create
 	r.POST("/seller/products", mustSeller(db), func(c *gin.Context) {
-		sess := sessions.Default(c)
-		email, _ := sess.Get("user_email").(string)
-		username, _ := sess.Get("user_username").(string)
+		u := c.MustGet("currentUser").(*models.User)
 
-		var u models.User
-		q := db
-		if email != "" {
-			q = q.Where("email = ?", email)
-		} else {
-			q = q.Where("username = ?", username)
+		in := productInput(c)
+		f, err := in.Validate()
+		if err == nil {
+			err = catalog.CheckSKU(db, u.ID, f.SKU, 0)
 		}
-		if err := q.First(&u).Error; err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-			return
+		if err == nil {
+			err = catalog.CheckPublish(db, u.ID, f.Status)
 		}
-
-		title := strings.TrimSpace(c.PostForm("title"))
-		desc := strings.TrimSpace(c.PostForm("description"))
-		price := strings.TrimSpace(c.PostForm("price"))
-		stock := strings.TrimSpace(c.PostForm("stock"))
-		if title == "" || price == "" || stock == "" {
+		if err != nil {
 			c.HTML(http.StatusBadRequest, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "create", "Error": "Fill title, price, stock",
-				"Form": ViewData{"Title": title, "Description": desc, "Price": price, "Stock": stock},
+				"Mode": "create", "Error": err.Error(), "Form": productForm(in, 0),
 			}))
 			return
 		}
-		price = strings.ReplaceAll(price, ",", ".")
-		var dollars, cents, priceCents, stockInt int
-		if strings.Contains(price, ".") {
-			fmt.Sscanf(price, "%d.%d", &dollars, &cents)
-			if cents > 99 {
-				cents = 99
-			}
-			priceCents = dollars*100 + cents
-		} else {
-			fmt.Sscanf(price, "%d", &dollars)
-			priceCents = dollars * 100
-		}
-		fmt.Sscanf(stock, "%d", &stockInt)
-		if stockInt < 0 {
-			stockInt = 0
-		}
 
 		imgPath, imgErr := saveUploadedImage(c, "image")
 		if imgErr != nil {
 			c.HTML(http.StatusBadRequest, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "create", "Error": imgErr.Error(),
-				"Form": ViewData{"Title": title, "Description": desc, "Price": price, "Stock": stock},
+				"Mode": "create", "Error": imgErr.Error(), "Form": productForm(in, 0),
 			}))
 			return
 		}
 
 		item := models.Product{
-			SellerID:    u.ID,
-			Title:       title,
-			Description: desc,
-			PriceCents:  priceCents,
-			Stock:       stockInt,
-			ImagePath:   imgPath,
-		}
-		if err := db.Create(&item).Error; err != nil {
+			SellerID:  u.ID,
+			ImagePath: imgPath,
+		}
+		f.ApplyTo(&item)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			return models.RecordRevision(tx, nil, item, models.ProductRevision{UserID: u.ID, Action: models.RevisionCreate})
+		})
+		if err != nil {
 			c.HTML(http.StatusInternalServerError, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "create", "Error": err.Error(),
-				"Form": ViewData{"Title": title, "Description": desc, "Price": price, "Stock": stock},
+				"Mode": "create", "Error": err.Error(), "Form": productForm(in, 0),
 			}))
 			return
 		}
@@ -451,114 +767,144 @@ func main() {
 
 	// Edit form
 	r.GET("/seller/products/:id/edit", mustSeller(db), func(c *gin.Context) {
-		id := c.Param("id")
-		var item models.Product
-		if err := db.First(&item, "id = ?", id).Error; err != nil {
-			c.String(http.StatusNotFound, "Not found")
-			return
-		}
-		// ownership
-		sess := sessions.Default(c)
-		email, _ := sess.Get("user_email").(string)
-		username, _ := sess.Get("user_username").(string)
-		var u models.User
-		if email != "" {
-			_ = db.Where("email = ?", email).First(&u).Error
-		} else {
-			_ = db.Where("username = ?", username).First(&u).Error
-		}
-		if item.SellerID != u.ID {
-			c.String(http.StatusForbidden, "Forbidden")
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
 			return
 		}
 		c.HTML(http.StatusOK, "seller_form.tmpl", withUser(c, ViewData{
-			"Mode": "edit", "Item": item,
-			"Form": ViewData{
-				"Title": item.Title, "Description": item.Description,
-				"Price": fmt.Sprintf("%.2f", float64(item.PriceCents)/100.0),
-				"Stock": item.Stock,
-			},
+			"Mode": "edit", "Item": item, "Form": productForm(catalog.FromProduct(*item), item.Version),
 		}))
 	})
 
 	// Update
 	r.POST("/seller/products/:id", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
 		id := c.Param("id")
-		var item models.Product
-		if err := db.First(&item, "id = ?", id).Error; err != nil {
-			c.String(http.StatusNotFound, "Not found")
+		found, ok := ownProduct(c, db, id)
+		if !ok {
 			return
 		}
-		// ownership
-		sess := sessions.Default(c)
-		email, _ := sess.Get("user_email").(string)
-		username, _ := sess.Get("user_username").(string)
-		var u models.User
-		if email != "" {
-			_ = db.Where("email = ?", email).First(&u).Error
-		} else {
-			_ = db.Where("username = ?", username).First(&u).Error
-		}
-		if item.SellerID != u.ID {
-			c.String(http.StatusForbidden, "Forbidden")
+		item := *found
+
+		in := productInput(c)
+		// версия, которую видел продавец, когда открыл форму; без неё не сохраняем
+		version, err := strconv.Atoi(c.PostForm("version"))
+		if err != nil || version < 1 {
+			c.String(http.StatusBadRequest, "The form has no product version. Reload the page and make your changes again.")
 			return
 		}
-
-		title := strings.TrimSpace(c.PostForm("title"))
-		desc := strings.TrimSpace(c.PostForm("description"))
-		price := strings.TrimSpace(c.PostForm("price"))
-		stock := strings.TrimSpace(c.PostForm("stock"))
-		if title == "" || price == "" || stock == "" {
+		f, err := in.Validate()
+		if err == nil {
+			err = catalog.CheckSKU(db, u.ID, f.SKU, item.ID)
+		}
+		if err == nil {
+			err = catalog.CheckPublish(db, u.ID, f.Status)
+		}
+		if err != nil {
 			c.HTML(http.StatusBadRequest, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "edit", "Error": "Fill title, price, stock",
-				"Item": item,
-				"Form": ViewData{"Title": title, "Description": desc, "Price": price, "Stock": stock},
+				"Mode": "edit", "Error": err.Error(), "Item": item, "Form": productForm(in, version),
 			}))
 			return
 		}
-		price = strings.ReplaceAll(price, ",", ".")
-		var dollars, cents, priceCents, stockInt int
-		if strings.Contains(price, ".") {
-			fmt.Sscanf(price, "%d.%d", &dollars, &cents)
-			if cents > 99 {
-				cents = 99
-			}
-			priceCents = dollars*100 + cents
-		} else {
-			fmt.Sscanf(price, "%d", &dollars)
-			priceCents = dollars * 100
-		}
-		fmt.Sscanf(stock, "%d", &stockInt)
-		if stockInt < 0 {
-			stockInt = 0
-		}
+
+		before := item
 
 		// optional new image
 		if imgPath, imgErr := saveUploadedImage(c, "image"); imgErr != nil {
 			c.HTML(http.StatusBadRequest, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "edit", "Error": imgErr.Error(), "Item": item,
-				"Form": ViewData{"Title": title, "Description": desc, "Price": price, "Stock": stock},
+				"Mode": "edit", "Error": imgErr.Error(), "Item": item, "Form": productForm(in, version),
 			}))
 			return
 		} else if imgPath != "" {
 			item.ImagePath = imgPath
 		}
 
-		item.Title = title
-		item.Description = desc
-		item.PriceCents = priceCents
-		item.Stock = stockInt
+		f.ApplyTo(&item)
 
-		if err := db.Save(&item).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := models.UpdateProduct(tx, &item, version); err != nil {
+				return err
+			}
+			return models.RecordRevision(tx, &before, item, models.ProductRevision{UserID: u.ID, Action: models.RevisionUpdate})
+		})
+		if errors.Is(err, models.ErrVersionConflict) {
+			// кто-то сохранил товар раньше: показываем, чем сохранённое отличается от наших правок.
+			// Форма уже с новой версией — повторное сохранение перезапишет их осознанно.
+			var current models.Product
+			if err := db.First(&current, "id = ?", id).Error; err != nil {
+				c.String(http.StatusNotFound, "Not found")
+				return
+			}
+			c.HTML(http.StatusConflict, "seller_form.tmpl", withUser(c, ViewData{
+				"Mode": "edit", "Item": current,
+				"Error":    "This product was changed by someone else while you were editing. Review the differences and save again to overwrite.",
+				"Conflict": models.SnapshotOf(current).Diff(models.SnapshotOf(item)),
+				"Form":     productForm(in, current.Version),
+			}))
+			return
+		}
+		if err != nil {
 			c.HTML(http.StatusInternalServerError, "seller_form.tmpl", withUser(c, ViewData{
-				"Mode": "edit", "Error": err.Error(), "Item": item,
+				"Mode": "edit", "Error": err.Error(), "Item": item, "Form": productForm(in, version),
 			}))
 			return
 		}
 		c.Redirect(http.StatusSeeOther, "/seller/products")
 	})
 
-	// Delete (безопасно — только один элемент продавца)
+	registerProductHistoryRoutes(r, db)
+	registerDashboardRoutes(r, db)
+	registerImportRoutes(r, db)
+	registerExportRoutes(r, db)
+	registerFeedRoutes(r, db)
+	registerExchangeRoutes(r, db)
+	registerAPIKeyRoutes(r, db)
+	registerSellerAPI(r, db)
+	registerBulkRoutes(r, db)
+	registerInventoryRoutes(r, db)
+	registerWarehouseRoutes(r, db)
+	registerShopRoutes(r, db)
+	registerOnboardingRoutes(r, db)
+	registerLedgerRoutes(r, db, payoutProvider, autoConfirm, hold)
+	registerDisputeRoutes(r, db)
+	registerReviewRoutes(r, db)
+	registerStockAlertRoutes(r, db)
+
+	// Archive — снять с витрины, не удаляя
+	r.POST("/seller/products/:id/archive", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		res := db.Model(&models.Product{}).
+			Where("id = ? AND seller_id = ? AND archived_at IS NULL", c.Param("id"), u.ID).
+			Update("archived_at", time.Now())
+		if res.Error != nil {
+			c.String(http.StatusInternalServerError, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.String(http.StatusForbidden, "Not your product or not found")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/products")
+	})
+
+	// Restore — вернуть из архива (и архивные, и удалённые)
+	r.POST("/seller/products/:id/restore", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		res := db.Unscoped().Model(&models.Product{}).
+			Where("id = ? AND seller_id = ?", c.Param("id"), u.ID).
+			Updates(map[string]any{"archived_at": nil, "deleted_at": nil})
+		if res.Error != nil {
+			c.String(http.StatusInternalServerError, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.String(http.StatusForbidden, "Not your product or not found")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/products?tab=archive")
+	})
+
+	// Delete (безопасно — только один элемент продавца; soft delete, см. models.Product.DeletedAt)
 	r.POST("/seller/products/:id/delete", mustSeller(db), func(c *gin.Context) {
 		id := c.Param("id")
 
@@ -605,12 +951,18 @@ func main() {
 
 		// validate product exists
 		var p models.Product
-		if err := db.First(&p, "id = ?", id).Error; err != nil {
+		if err := db.Scopes(models.ListedProducts).First(&p, "id = ?", id).Error; err != nil {
 			c.String(http.StatusNotFound, "product not found")
 			return
 		}
 		if p.Stock <= 0 {
-			c.String(http.StatusBadRequest, "out of stock")
+			// на карточке товара можно подписаться на поступление
+			c.Redirect(http.StatusSeeOther, fmt.Sprintf("/product/%d?out=1", p.ID))
+			return
+		}
+		// продавец в отпуске — на карточке написано, когда вернётся
+		if away, _ := models.SellerAway(db, p.SellerID); away != nil {
+			c.Redirect(http.StatusSeeOther, fmt.Sprintf("/product/%d", p.ID))
 			return
 		}
 
@@ -658,25 +1010,25 @@ func main() {
 	})
 
 	r.GET("/cart", func(c *gin.Context) {
-		cart := getCart(c)
-		type Row struct {
-			Product       models.Product
-			Qty           int
-			SubtotalCents int
-		}
-		var rows []Row
-		total := 0
-		for id, q := range cart {
-			var p models.Product
-			if err := db.First(&p, "id = ?", id).Error; err == nil {
-				sub := p.PriceCents * q
-				rows = append(rows, Row{Product: p, Qty: q, SubtotalCents: sub})
-				total += sub
-			}
+		c.HTML(http.StatusOK, "cart.tmpl", withUser(c, cartView(c, db, settlement)))
+	})
+
+	// валюта показа цен
+	r.POST("/currency", func(c *gin.Context) {
+		if cur := money.Currency(c.PostForm("currency")); cur.Known() {
+			sess := sessions.Default(c)
+			sess.Set(currencyKey, string(cur))
+			_ = sess.Save()
+		}
+		back := c.Request.Referer()
+		if back == "" {
+			back = "/"
 		}
-		c.HTML(http.StatusOK, "cart.tmpl", withUser(c, ViewData{"Rows": rows, "TotalCents": total}))
+		c.Redirect(http.StatusSeeOther, back)
 	})
 
+	registerOrderRoutes(r, db, rates, settlement, paymentProvider, autoConfirm)
+
 	// start
 	port := os.Getenv("APP_PORT")
 	if port == "" {
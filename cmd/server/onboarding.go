@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/onboarding"
+)
+
+// verificationOf — анкета продавца с документами; nil, если её ещё нет
+func verificationOf(db *gorm.DB, sellerID uint) (*models.SellerVerification, error) {
+	var v models.SellerVerification
+	err := db.Preload("Documents").First(&v, "seller_id = ?", sellerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// onboardingInput — поля анкеты из формы
+func onboardingInput(c *gin.Context) onboarding.Input {
+	return onboarding.Input{
+		LegalForm:   c.PostForm("legal_form"),
+		LegalName:   c.PostForm("legal_name"),
+		INN:         c.PostForm("inn"),
+		OGRN:        c.PostForm("ogrn"),
+		BankName:    c.PostForm("bank_name"),
+		BIK:         c.PostForm("bik"),
+		Account:     c.PostForm("account"),
+		CorrAccount: c.PostForm("corr_account"),
+	}
+}
+
+// Анкета продавца (реквизиты и документы) и очередь проверки для модераторов
+func registerOnboardingRoutes(r *gin.Engine, db *gorm.DB) {
+	page := func(c *gin.Context, status int, v *models.SellerVerification, form *onboarding.Input, errMsg string) {
+		if form == nil {
+			in := onboarding.Input{LegalForm: string(models.LegalIndividual)}
+			if v != nil {
+				in = onboarding.FromVerification(*v)
+			}
+			form = &in
+		}
+		c.HTML(status, "onboarding.tmpl", withUser(c, ViewData{
+			"Verification": v, "Form": form, "Error": errMsg, "LegalForms": models.LegalForms,
+			"Extensions": strings.Join(onboarding.Extensions, ","), "MaxDocuments": onboarding.MaxDocuments,
+		}))
+	}
+	// current — пользователь и его анкета; ошибку пишет сам
+	current := func(c *gin.Context) (*models.User, *models.SellerVerification, bool) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return nil, nil, false
+		}
+		v, err := verificationOf(db, u.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return nil, nil, false
+		}
+		return u, v, true
+	}
+	back := func(c *gin.Context) { c.Redirect(http.StatusSeeOther, "/seller/onboarding") }
+
+	r.GET("/seller/onboarding", mustLogin(), func(c *gin.Context) {
+		if _, v, ok := current(c); ok {
+			page(c, http.StatusOK, v, nil, "")
+		}
+	})
+
+	// Details — сохранить реквизиты. Первое сохранение делает пользователя продавцом:
+	// дальше он готовит черновики, пока анкета на проверке.
+	r.POST("/seller/onboarding", mustLogin(), func(c *gin.Context) {
+		u, v, ok := current(c)
+		if !ok {
+			return
+		}
+		in := onboardingInput(c)
+		if v != nil && !v.Editable() {
+			page(c, http.StatusConflict, v, &in, "The application is "+string(v.Status)+" and can't be changed")
+			return
+		}
+		next := models.SellerVerification{SellerID: u.ID, Status: models.VerificationDraft}
+		if v != nil {
+			next = *v
+		}
+		if err := in.Validate(&next); err != nil {
+			page(c, http.StatusBadRequest, v, &in, err.Error())
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Documents").Save(&next).Error; err != nil {
+				return err
+			}
+			if u.Role == models.RoleBuyer {
+				return tx.Model(u).Update("role", models.RoleSeller).Error
+			}
+			return nil
+		})
+		if err != nil {
+			page(c, http.StatusInternalServerError, v, &in, err.Error())
+			return
+		}
+		back(c)
+	})
+
+	// Documents — паспорт, свидетельство ИНН/ОГРН, выписка; хранятся вне /uploads
+	r.POST("/seller/onboarding/documents", mustSeller(db), func(c *gin.Context) {
+		_, v, ok := current(c)
+		if !ok {
+			return
+		}
+		if v == nil || !v.Editable() {
+			page(c, http.StatusConflict, v, nil, "Save your legal details first")
+			return
+		}
+		form, err := c.MultipartForm()
+		if err != nil || len(form.File["documents"]) == 0 {
+			page(c, http.StatusBadRequest, v, nil, "Choose a file")
+			return
+		}
+		files := form.File["documents"]
+		if len(v.Documents)+len(files) > onboarding.MaxDocuments {
+			page(c, http.StatusBadRequest, v, nil, fmt.Sprintf("At most %d documents", onboarding.MaxDocuments))
+			return
+		}
+		_ = os.MkdirAll(onboarding.Dir, 0o700)
+		for _, file := range files {
+			ext := strings.ToLower(filepath.Ext(file.Filename))
+			if !slices.Contains(onboarding.Extensions, ext) {
+				page(c, http.StatusBadRequest, v, nil, file.Filename+": use PDF, JPG or PNG")
+				return
+			}
+			if file.Size > onboarding.MaxFileSize {
+				page(c, http.StatusBadRequest, v, nil, fmt.Sprintf("%s is larger than %d MB", file.Filename, onboarding.MaxFileSize>>20))
+				return
+			}
+			name := fmt.Sprintf("%d-%d%s", v.SellerID, time.Now().UnixNano(), ext)
+			if err := c.SaveUploadedFile(file, filepath.Join(onboarding.Dir, name)); err != nil {
+				page(c, http.StatusInternalServerError, v, nil, err.Error())
+				return
+			}
+			doc := models.VerificationDocument{VerificationID: v.ID, FileName: filepath.Base(file.Filename), Path: name, Size: file.Size}
+			if err := db.Create(&doc).Error; err != nil {
+				_ = os.Remove(filepath.Join(onboarding.Dir, name))
+				page(c, http.StatusInternalServerError, v, nil, err.Error())
+				return
+			}
+		}
+		back(c)
+	})
+
+	r.POST("/seller/onboarding/documents/:doc/delete", mustSeller(db), func(c *gin.Context) {
+		_, v, ok := current(c)
+		if !ok {
+			return
+		}
+		if v == nil || !v.Editable() {
+			c.String(http.StatusConflict, "Application can't be changed")
+			return
+		}
+		var doc models.VerificationDocument
+		if err := db.First(&doc, "id = ? AND verification_id = ?", c.Param("doc"), v.ID).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		if err := db.Delete(&doc).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		_ = os.Remove(filepath.Join(onboarding.Dir, doc.Path))
+		back(c)
+	})
+
+	// Bank — реквизиты для выплат у одобренного продавца, у которого их не было
+	r.POST("/seller/onboarding/bank", mustSeller(db), func(c *gin.Context) {
+		_, v, ok := current(c)
+		if !ok {
+			return
+		}
+		if v == nil || !v.BankEditable() {
+			c.String(http.StatusConflict, "Bank details can't be changed")
+			return
+		}
+		in := onboardingInput(c)
+		next := *v
+		if err := in.ValidateBank(&next); err != nil {
+			page(c, http.StatusBadRequest, v, &in, err.Error())
+			return
+		}
+		err := db.Model(v).Where("bik = '' OR account = ''").Updates(map[string]any{
+			"bank_name": next.BankName, "bik": next.BIK, "account": next.Account, "corr_account": next.CorrAccount,
+		}).Error
+		if err != nil {
+			page(c, http.StatusInternalServerError, v, &in, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/balance")
+	})
+
+	r.POST("/seller/onboarding/submit", mustSeller(db), func(c *gin.Context) {
+		_, v, ok := current(c)
+		if !ok {
+			return
+		}
+		if v == nil {
+			page(c, http.StatusBadRequest, v, nil, "Fill in your legal details first")
+			return
+		}
+		if err := onboarding.Submit(db, v); err != nil {
+			page(c, http.StatusBadRequest, v, nil, err.Error())
+			return
+		}
+		back(c)
+	})
+
+	// ------ модерация ------
+	r.GET("/admin/verifications", mustAdmin(db), func(c *gin.Context) {
+		status := models.VerificationStatus(c.DefaultQuery("status", string(models.VerificationPending)))
+		var list []models.SellerVerification
+		// очередь — по времени отправки, старые первыми
+		if err := db.Where("status = ?", status).Order("submitted_at, id").Limit(200).Find(&list).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.HTML(http.StatusOK, "verifications.tmpl", withUser(c, ViewData{
+			"List": list, "Status": status, "Sellers": usernames(db, sellerIDs(list)),
+			"Statuses": []models.VerificationStatus{models.VerificationPending, models.VerificationRejected, models.VerificationApproved, models.VerificationDraft},
+		}))
+	})
+
+	review := func(c *gin.Context, status int, v *models.SellerVerification, errMsg string) {
+		c.HTML(status, "verification.tmpl", withUser(c, ViewData{
+			"V": v, "Seller": usernames(db, []uint{v.SellerID})[v.SellerID], "Error": errMsg,
+		}))
+	}
+	load := func(c *gin.Context) (*models.SellerVerification, bool) {
+		var v models.SellerVerification
+		if err := db.Preload("Documents").First(&v, "id = ?", c.Param("id")).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return nil, false
+		}
+		return &v, true
+	}
+
+	r.GET("/admin/verifications/:id", mustAdmin(db), func(c *gin.Context) {
+		if v, ok := load(c); ok {
+			review(c, http.StatusOK, v, "")
+		}
+	})
+
+	// документ отдаётся вложением, чтобы браузер не исполнял загруженное продавцом
+	r.GET("/admin/verifications/:id/documents/:doc", mustAdmin(db), func(c *gin.Context) {
+		var doc models.VerificationDocument
+		if err := db.First(&doc, "id = ? AND verification_id = ?", c.Param("doc"), c.Param("id")).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.FileAttachment(filepath.Join(onboarding.Dir, doc.Path), doc.FileName)
+	})
+
+	r.POST("/admin/verifications/:id/review", mustAdmin(db), func(c *gin.Context) {
+		admin := c.MustGet("currentUser").(*models.User)
+		v, ok := load(c)
+		if !ok {
+			return
+		}
+		approve := c.PostForm("decision") == "approve"
+		if err := onboarding.Review(db, v, admin.ID, approve, c.PostForm("reason")); err != nil {
+			review(c, http.StatusBadRequest, v, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/admin/verifications")
+	})
+}
+
+// sellerIDs — продавцы анкет
+func sellerIDs(list []models.SellerVerification) []uint {
+	ids := make([]uint, len(list))
+	for i, v := range list {
+		ids[i] = v.SellerID
+	}
+	return ids
+}
+
+// usernames — имена пользователей по id
+func usernames(db *gorm.DB, ids []uint) map[uint]string {
+	var users []models.User
+	_ = db.Where("id IN ?", ids).Find(&users).Error
+	out := map[uint]string{}
+	for _, u := range users {
+		out[u.ID] = u.Username
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/fx"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+	"marketplace/internal/orders"
+	"marketplace/internal/payments"
+)
+
+// cartRow — строка корзины; суммы уже в валюте показа
+type cartRow struct {
+	Product  models.Product
+	Qty      int
+	Subtotal money.Money
+}
+
+// sellerOrder — часть заказа продавца: отправление и его позиции
+type sellerOrder struct {
+	Shipment models.Shipment
+	Order    models.Order
+}
+
+// cartView собирает корзину из сессии: суммы в валюте показа и итог к оплате в валюте расчётов.
+// Снятые с продажи товары из корзины выкидываются.
+func cartView(c *gin.Context, db *gorm.DB, settlement money.Currency) ViewData {
+	conv := c.MustGet("fx").(fx.Converter)
+	cart := getCart(c)
+	var rows []cartRow
+	total := money.New(0, conv.To)
+	pay := money.New(0, settlement)
+	var rateErr error
+	pruned := false
+	for id, q := range cart {
+		var p models.Product
+		if err := db.Scopes(models.ListedProducts).First(&p, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// товар сняли с продажи или удалили — убираем из корзины
+				delete(cart, id)
+				pruned = true
+			}
+			continue
+		}
+		// как и при оформлении: сначала цена за штуку в нужной валюте, потом × количество
+		unit, err := conv.Table.Convert(p.Price(), conv.To)
+		if err != nil {
+			rateErr, unit = err, p.Price()
+		}
+		sub := unit.Mul(int64(q))
+		rows = append(rows, cartRow{Product: p, Qty: q, Subtotal: sub})
+		total.Amount += sub.Amount
+		if s, err := conv.Table.Convert(p.Price(), settlement); err == nil {
+			pay.Amount += s.Mul(int64(q)).Amount
+		} else {
+			rateErr = err
+		}
+	}
+	if pruned {
+		saveCart(c, cart)
+	}
+	sellers := make([]uint, len(rows))
+	for i, row := range rows {
+		sellers[i] = row.Product.SellerID
+	}
+	data := ViewData{"Rows": rows, "Total": total, "Pay": pay, "Shops": shopsFor(db, sellers)}
+	if rateErr != nil {
+		data["RateError"] = rateErr.Error()
+	}
+	return data
+}
+
+// Оформление заказа и заказы покупателя
+func registerOrderRoutes(r *gin.Engine, db *gorm.DB, rates *fx.Cache, settlement money.Currency, payment payments.Provider, autoConfirm time.Duration) {
+	r.POST("/checkout", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		cart := getCart(c)
+		lines := make([]orders.Line, 0, len(cart))
+		for id, q := range cart {
+			pid, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				continue
+			}
+			lines = append(lines, orders.Line{ProductID: uint(pid), Qty: q})
+		}
+		order, err := orders.Place(db, u.ID, lines, rates.Table(), settlement)
+		if err == nil {
+			order, err = orders.Pay(c.Request.Context(), db, payment, order.ID)
+		}
+		switch {
+		case errors.Is(err, payments.ErrDeclined):
+			// резерв снят, корзина остаётся — можно попробовать другой картой
+			data := cartView(c, db, settlement)
+			data["Error"] = "Payment declined: " + err.Error()
+			c.HTML(http.StatusPaymentRequired, "cart.tmpl", withUser(c, data))
+		case order == nil:
+			data := cartView(c, db, settlement)
+			data["Error"] = err.Error()
+			c.HTML(http.StatusConflict, "cart.tmpl", withUser(c, data))
+		case err != nil:
+			// заказ ждёт оплаты с зарезервированным товаром — оплату можно повторить со страницы заказа
+			saveCart(c, map[string]int{})
+			c.Redirect(http.StatusSeeOther, "/orders/"+strconv.Itoa(int(order.ID))+"?error="+url.QueryEscape("Payment did not go through, try again"))
+		default:
+			saveCart(c, map[string]int{})
+			c.Redirect(http.StatusSeeOther, "/orders/"+strconv.Itoa(int(order.ID)))
+		}
+	})
+
+	// Pay — повтор оплаты заказа, который остался в ожидании после сбоя шлюза
+	r.POST("/orders/:id/pay", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		var order models.Order
+		if err := db.Select("id").First(&order, "id = ? AND buyer_id = ?", c.Param("id"), u.ID).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		back := "/orders/" + c.Param("id")
+		switch _, err := orders.Pay(c.Request.Context(), db, payment, order.ID); {
+		case errors.Is(err, orders.ErrNotPending):
+			c.Redirect(http.StatusSeeOther, back+"?error="+url.QueryEscape("The order is not awaiting payment"))
+		case errors.Is(err, payments.ErrDeclined):
+			c.Redirect(http.StatusSeeOther, back+"?error="+url.QueryEscape("Payment declined: "+err.Error()))
+		case err != nil:
+			c.Redirect(http.StatusSeeOther, back+"?error="+url.QueryEscape("Payment did not go through, try again"))
+		default:
+			c.Redirect(http.StatusSeeOther, back)
+		}
+	})
+
+	r.GET("/orders", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		var list []models.Order
+		if err := db.Where("buyer_id = ?", u.ID).Order("id desc").Find(&list).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.HTML(http.StatusOK, "orders.tmpl", withUser(c, ViewData{"Orders": list}))
+	})
+
+	r.GET("/orders/:id", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		var order models.Order
+		err = db.Preload("Items").Preload("Shipments", func(q *gorm.DB) *gorm.DB { return q.Order("id") }).
+			First(&order, "id = ? AND buyer_id = ?", c.Param("id"), u.ID).Error
+		if err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		sellers := make([]uint, len(order.Items))
+		for i, it := range order.Items {
+			sellers[i] = it.SellerID
+		}
+		// последний спор по каждой позиции
+		var list []models.Dispute
+		_ = db.Where("order_id = ?", order.ID).Order("id").Find(&list).Error
+		byItem := map[uint]models.Dispute{}
+		for _, d := range list {
+			byItem[d.OrderItemID] = d
+		}
+		var written []models.Review
+		_ = db.Select("id", "order_item_id", "rating").Where("order_item_id IN (?)", db.Model(&models.OrderItem{}).Select("id").Where("order_id = ?", order.ID)).Find(&written).Error
+		reviewed := map[uint]models.Review{}
+		for _, rv := range written {
+			reviewed[rv.OrderItemID] = rv
+		}
+		var feedback []models.SellerFeedback
+		_ = db.Where("order_id = ?", order.ID).Find(&feedback).Error
+		rated := map[uint]models.SellerFeedback{}
+		for _, f := range feedback {
+			rated[f.SellerID] = f
+		}
+		c.HTML(http.StatusOK, "order.tmpl", withUser(c, ViewData{
+			"Order": order, "Shops": shopsFor(db, sellers), "AutoConfirm": autoConfirm, "Error": c.Query("error"),
+			"Disputes": byItem, "Reviews": reviewed, "Feedback": rated,
+		}))
+	})
+
+	// Confirm — покупатель получил часть заказа от продавца seller_id: выручка уходит продавцу
+	r.POST("/orders/:id/confirm", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		orderID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		sellerID, _ := strconv.ParseUint(c.PostForm("seller_id"), 10, 64)
+		err = orders.Confirm(db, uint(orderID), u.ID, uint(sellerID))
+		switch {
+		case errors.Is(err, orders.ErrNoShipment):
+			c.String(http.StatusNotFound, "Not found")
+		case errors.Is(err, orders.ErrShipmentState):
+			c.Redirect(http.StatusSeeOther, "/orders/"+c.Param("id")+"?error="+url.QueryEscape("Delivery is already confirmed"))
+		case err != nil:
+			c.String(http.StatusInternalServerError, err.Error())
+		default:
+			c.Redirect(http.StatusSeeOther, "/orders/"+c.Param("id"))
+		}
+	})
+
+	// ------ заказы продавца ------
+	r.GET("/seller/orders", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		status := models.ShipmentStatus(c.DefaultQuery("status", string(models.ShipmentAwaiting)))
+		var list []models.Shipment
+		if err := db.Where("seller_id = ? AND status = ?", u.ID, status).Order("id desc").Limit(100).Find(&list).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		ids := make([]uint, len(list))
+		for i, s := range list {
+			ids[i] = s.OrderID
+		}
+		var ordersList []models.Order
+		if len(ids) > 0 {
+			// покупателю видны все позиции, продавцу — только свои
+			if err := db.Preload("Items", "seller_id = ?", u.ID).Find(&ordersList, ids).Error; err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		byID := map[uint]models.Order{}
+		var buyers []uint
+		for _, o := range ordersList {
+			byID[o.ID] = o
+			buyers = append(buyers, o.BuyerID)
+		}
+		rows := make([]sellerOrder, 0, len(list))
+		for _, s := range list {
+			rows = append(rows, sellerOrder{Shipment: s, Order: byID[s.OrderID]})
+		}
+		c.HTML(http.StatusOK, "seller_orders.tmpl", withUser(c, ViewData{
+			"Rows": rows, "Buyers": usernames(db, buyers), "Status": status, "AutoConfirmDays": int(autoConfirm.Hours() / 24), "Error": c.Query("error"),
+			"Statuses": []models.ShipmentStatus{models.ShipmentAwaiting, models.ShipmentShipped, models.ShipmentDelivered, models.ShipmentCancelled},
+		}))
+	})
+
+	// Ship — отметка об отправке с номером для отслеживания
+	r.POST("/seller/orders/:id/ship", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		orderID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		carrier := strings.TrimSpace(c.PostForm("carrier"))
+		tracking := strings.TrimSpace(c.PostForm("tracking_number"))
+		err := orders.Ship(db, uint(orderID), u.ID, carrier, tracking)
+		switch {
+		case errors.Is(err, orders.ErrNoShipment):
+			c.String(http.StatusNotFound, "Not found")
+		case errors.Is(err, orders.ErrShipmentState):
+			c.Redirect(http.StatusSeeOther, "/seller/orders?error="+url.QueryEscape("Order #"+c.Param("id")+" is already shipped"))
+		case err != nil:
+			c.String(http.StatusInternalServerError, err.Error())
+		default:
+			c.Redirect(http.StatusSeeOther, "/seller/orders")
+		}
+	})
+}
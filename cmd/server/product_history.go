@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/uploads"
+)
+
+// История правок товара и откат к ревизии
+func registerProductHistoryRoutes(r *gin.Engine, db *gorm.DB) {
+	r.GET("/seller/products/:id/history", mustSeller(db), func(c *gin.Context) {
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		var revs []models.ProductRevision
+		if err := db.Where("product_id = ?", item.ID).Order("id desc").Find(&revs).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// имена авторов правок
+		ids := make([]uint, 0, len(revs))
+		for _, rv := range revs {
+			ids = append(ids, rv.UserID)
+		}
+		var users []models.User
+		_ = db.Where("id IN ?", ids).Find(&users).Error
+		authors := map[uint]string{}
+		for _, u := range users {
+			authors[u.ID] = u.Username
+		}
+		c.HTML(http.StatusOK, "product_history.tmpl", withUser(c, ViewData{
+			"Item": item, "Revisions": revs, "Authors": authors,
+		}))
+	})
+
+	// Rollback — вернуть поля товара к состоянию ревизии; сам откат тоже пишется ревизией
+	r.POST("/seller/products/:id/history/:rev/rollback", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		item, ok := ownProduct(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		var rev models.ProductRevision
+		if err := db.First(&rev, "id = ? AND product_id = ?", c.Param("rev"), item.ID).Error; err != nil {
+			c.String(http.StatusNotFound, "Revision not found")
+			return
+		}
+		before := *item
+		// если вернули scheduled с прошедшей датой — его опубликует планировщик
+		rev.Snapshot.ApplyTo(item)
+		if item.ImagePath != before.ImagePath && item.ImagePath != "" && !uploads.Exists(item.ImagePath) {
+			item.ImagePath = before.ImagePath // картинку старой ревизии уже убрал GC (uploads.Collector.History)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := models.UpdateProduct(tx, item, before.Version); err != nil {
+				return err
+			}
+			return models.RecordRevision(tx, &before, *item, models.ProductRevision{
+				UserID: u.ID, Action: models.RevisionRollback, RollbackTo: &rev.ID,
+			})
+		})
+		if errors.Is(err, models.ErrVersionConflict) {
+			c.String(http.StatusConflict, "Product was changed while rolling back, reload history and try again")
+			return
+		}
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/products/"+c.Param("id")+"/history")
+	})
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/reviews"
+)
+
+// catalogOrder — порядок товаров на витрине по ?sort=: rating — сначала с лучшим рейтингом, иначе новые
+func catalogOrder(sort string) string {
+	if sort == "rating" {
+		return "products.rating_avg DESC, products.rating_count DESC, products.id DESC"
+	}
+	return "products.id DESC"
+}
+
+// productReviews — опубликованные отзывы о товаре, свежие первыми, и имена авторов
+func productReviews(db *gorm.DB, productID uint) ([]models.Review, map[uint]string) {
+	var list []models.Review
+	_ = db.Preload("Photos").
+		Where("product_id = ? AND status = ?", productID, models.ReviewPublished).
+		Order("id desc").Limit(50).
+		Find(&list).Error
+	ids := make([]uint, len(list))
+	for i, r := range list {
+		ids[i] = r.BuyerID
+	}
+	return list, usernames(db, ids)
+}
+
+// reviewList — отзывы для кабинета продавца и модерации с названиями товаров и именами авторов
+func reviewList(c *gin.Context, db *gorm.DB, q *gorm.DB) (ViewData, bool) {
+	var list []models.Review
+	if err := q.Preload("Photos").Order("id desc").Limit(200).Find(&list).Error; err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	var users, products []uint
+	for _, r := range list {
+		users = append(users, r.BuyerID, r.SellerID)
+		products = append(products, r.ProductID)
+	}
+	titles := map[uint]string{}
+	var items []models.Product
+	if len(products) > 0 {
+		_ = db.Unscoped().Select("id", "title").Find(&items, products).Error
+	}
+	for _, p := range items {
+		titles[p.ID] = p.Title
+	}
+	return ViewData{"Reviews": list, "Names": usernames(db, users), "Titles": titles}, true
+}
+
+// buyerIDs — авторы оценок продавца
+func buyerIDs(list []models.SellerFeedback) []uint {
+	ids := make([]uint, len(list))
+	for i, f := range list {
+		ids[i] = f.BuyerID
+	}
+	return ids
+}
+
+// Отзывы о товарах: от покупателей полученных заказов, ответы продавцов, модерация; оценки продавцов
+func registerReviewRoutes(r *gin.Engine, db *gorm.DB) {
+	form := func(c *gin.Context, status int, order *models.Order, item *models.OrderItem, review *models.Review, in *reviews.Input, errMsg string) {
+		if in == nil {
+			in = &reviews.Input{Rating: 5}
+			if review != nil {
+				in.Rating, in.Body = review.Rating, review.Body
+			}
+		}
+		c.HTML(status, "review_form.tmpl", withUser(c, ViewData{
+			"Order": order, "Item": item, "Review": review, "Form": in, "MaxPhotos": reviews.MaxPhotos,
+			"Editable": review == nil || reviews.Editable(*review, time.Now()), "Error": errMsg,
+			"Stars": []int{5, 4, 3, 2, 1},
+		}))
+	}
+
+	r.GET("/orders/:id/items/:item/review", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		order, item, ok := buyerOrderItem(c, db, u)
+		if !ok {
+			return
+		}
+		review, err := reviews.Of(db, item.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		form(c, http.StatusOK, order, item, review, nil, "")
+	})
+
+	r.POST("/orders/:id/items/:item/review", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		order, item, ok := buyerOrderItem(c, db, u)
+		if !ok {
+			return
+		}
+		rating, _ := strconv.Atoi(c.PostForm("rating"))
+		in := reviews.Input{Rating: rating, Body: c.PostForm("body")}
+		review, _ := reviews.Of(db, item.ID)
+		in.Photos, err = saveUploadedImages(c, "photos", reviews.MaxPhotos)
+		if err == nil {
+			_, err = reviews.Save(db, u.ID, *item, in)
+		}
+		if err != nil {
+			status := http.StatusUnprocessableEntity
+			if !errors.Is(err, reviews.ErrInvalid) && !errors.Is(err, reviews.ErrLocked) && !errors.Is(err, reviews.ErrNotDelivered) {
+				status = http.StatusInternalServerError
+			}
+			form(c, status, order, item, review, &in, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/product/"+strconv.Itoa(int(item.ProductID))+"#reviews")
+	})
+
+	// ------ оценка продавца ------
+	// shipment — часть заказа :id от продавца :seller у покупателя u; при ошибке пишет 404 сам
+	shipment := func(c *gin.Context, u *models.User) (*models.Shipment, bool) {
+		var s models.Shipment
+		err := db.Joins("JOIN orders o ON o.id = shipments.order_id").
+			Where("shipments.order_id = ? AND shipments.seller_id = ? AND o.buyer_id = ?", c.Param("id"), c.Param("seller"), u.ID).
+			First(&s).Error
+		if err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return nil, false
+		}
+		return &s, true
+	}
+	feedbackForm := func(c *gin.Context, status int, s *models.Shipment, f *models.SellerFeedback, in *reviews.FeedbackInput, errMsg string) {
+		if in == nil {
+			in = &reviews.FeedbackInput{Shipping: 5, Communication: 5, Accuracy: 5}
+			if f != nil {
+				in = &reviews.FeedbackInput{Shipping: f.Shipping, Communication: f.Communication, Accuracy: f.Accuracy, Comment: f.Comment}
+			}
+		}
+		c.HTML(status, "seller_feedback.tmpl", withUser(c, ViewData{
+			"Shipment": s, "Shop": shopsFor(db, []uint{s.SellerID})[s.SellerID], "Feedback": f, "Form": in,
+			"Editable": f == nil || time.Since(f.CreatedAt) <= reviews.EditWindow, "Error": errMsg,
+			"Stars": []int{5, 4, 3, 2, 1},
+		}))
+	}
+
+	r.GET("/orders/:id/sellers/:seller/feedback", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		s, ok := shipment(c, u)
+		if !ok {
+			return
+		}
+		f, err := reviews.FeedbackOf(db, s.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		feedbackForm(c, http.StatusOK, s, f, nil, "")
+	})
+
+	r.POST("/orders/:id/sellers/:seller/feedback", mustLogin(), func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		s, ok := shipment(c, u)
+		if !ok {
+			return
+		}
+		score := func(name string) int { n, _ := strconv.Atoi(c.PostForm(name)); return n }
+		in := reviews.FeedbackInput{
+			Shipping: score("shipping"), Communication: score("communication"), Accuracy: score("accuracy"), Comment: c.PostForm("comment"),
+		}
+		if _, err := reviews.SaveFeedback(db, u.ID, *s, in); err != nil {
+			status := http.StatusUnprocessableEntity
+			if !errors.Is(err, reviews.ErrInvalid) && !errors.Is(err, reviews.ErrLocked) && !errors.Is(err, reviews.ErrNotDelivered) {
+				status = http.StatusInternalServerError
+			}
+			f, _ := reviews.FeedbackOf(db, s.ID)
+			feedbackForm(c, status, s, f, &in, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/orders/"+c.Param("id"))
+	})
+
+	// ------ продавец ------
+	r.GET("/seller/reviews", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		if data, ok := reviewList(c, db, db.Where("seller_id = ?", u.ID)); ok {
+			var feedback []models.SellerFeedback
+			_ = db.Where("seller_id = ?", u.ID).Order("id desc").Limit(50).Find(&feedback).Error
+			var rating models.SellerRating
+			_ = db.First(&rating, "seller_id = ?", u.ID).Error
+			names := data["Names"].(map[uint]string)
+			for id, name := range usernames(db, buyerIDs(feedback)) {
+				names[id] = name
+			}
+			data["Feedback"], data["Rating"], data["Error"] = feedback, rating, c.Query("error")
+			c.HTML(http.StatusOK, "seller_reviews.tmpl", withUser(c, data))
+		}
+	})
+
+	r.POST("/seller/reviews/:id/reply", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		err := reviews.Reply(db, uint(id), u.ID, c.PostForm("reply"))
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.String(http.StatusNotFound, "Not found")
+		case errors.Is(err, reviews.ErrInvalid):
+			c.Redirect(http.StatusSeeOther, "/seller/reviews?error="+url.QueryEscape(err.Error()))
+		case err != nil:
+			c.String(http.StatusInternalServerError, err.Error())
+		default:
+			c.Redirect(http.StatusSeeOther, "/seller/reviews")
+		}
+	})
+
+	// ------ модерация ------
+	r.GET("/admin/reviews", mustAdmin(db), func(c *gin.Context) {
+		status := models.ReviewStatus(c.DefaultQuery("status", string(models.ReviewPublished)))
+		if data, ok := reviewList(c, db, db.Where("status = ?", status)); ok {
+			data["Status"], data["Error"] = status, c.Query("error")
+			data["Statuses"] = []models.ReviewStatus{models.ReviewPublished, models.ReviewHidden}
+			c.HTML(http.StatusOK, "review_queue.tmpl", withUser(c, data))
+		}
+	})
+
+	r.POST("/admin/reviews/:id/moderate", mustAdmin(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		hide := c.PostForm("action") == "hide"
+		err := reviews.Moderate(db, uint(id), u.ID, hide, c.PostForm("reason"))
+		back := "/admin/reviews"
+		if !hide {
+			back += "?status=" + string(models.ReviewHidden)
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.String(http.StatusNotFound, "Not found")
+		case errors.Is(err, reviews.ErrInvalid):
+			c.Redirect(http.StatusSeeOther, "/admin/reviews?error="+url.QueryEscape(err.Error()))
+		case err != nil:
+			c.String(http.StatusInternalServerError, err.Error())
+		default:
+			c.Redirect(http.StatusSeeOther, back)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+)
+
+// apiBatchLimit — сколько товаров можно обновить одним запросом
+const apiBatchLimit = 500
+
+// apiError — ошибка API в JSON
+func apiError(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, gin.H{"error": msg})
+}
+
+// mustAPIKey — аутентификация по ключу продавца (Authorization: Bearer mpk_...) с проверкой права.
+// Ставит currentUser, как mustSeller.
+func mustAPIKey(db *gorm.DB, scope models.APIScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(raw) == "" {
+			apiError(c, http.StatusUnauthorized, "missing API key")
+			return
+		}
+		var key models.APIKey
+		if err := db.Where("hash = ? AND revoked_at IS NULL", models.HashAPIKey(raw)).First(&key).Error; err != nil {
+			apiError(c, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		if !key.Allows(scope) {
+			apiError(c, http.StatusForbidden, "key has no "+string(scope)+" scope")
+			return
+		}
+		var u models.User
+		if err := db.First(&u, key.SellerID).Error; err != nil || u.Role != models.RoleSeller {
+			apiError(c, http.StatusForbidden, "seller account is not active")
+			return
+		}
+		_ = db.Model(&key).UpdateColumn("last_used_at", time.Now()).Error
+		c.Set("currentUser", &u)
+		c.Next()
+	}
+}
+
+// apiProduct — товар в ответах API
+type apiProduct struct {
+	ID          uint       `json:"id"`
+	SKU         string     `json:"sku"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Price       string     `json:"price"`
+	Currency    string     `json:"currency"`
+	Stock       int        `json:"stock"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	LowStock    int        `json:"low_stock"`
+	ImageURL    string     `json:"image_url,omitempty"`
+	Archived    bool       `json:"archived"`
+	Version     int        `json:"version"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func toAPIProduct(c *gin.Context, p models.Product) apiProduct {
+	out := apiProduct{
+		ID:          p.ID,
+		SKU:         p.SKU,
+		Title:       p.Title,
+		Description: p.Description,
+		Price:       p.Price().Decimal(),
+		Currency:    string(p.Currency),
+		Stock:       p.Stock,
+		Status:      string(p.Status),
+		PublishAt:   p.PublishAt,
+		LowStock:    p.LowStock,
+		Archived:    p.Archived(),
+		Version:     p.Version,
+		UpdatedAt:   p.UpdatedAt,
+	}
+	if p.ImagePath != "" {
+		out.ImageURL = baseURL(c) + p.ImagePath
+	}
+	return out
+}
+
+// apiProductInput — создание или частичная правка; отсутствующие поля не меняются.
+// Для пакетной правки товар ищется по id или sku; version обязательна — защита от затирания чужих правок.
+type apiProductInput struct {
+	ID          uint         `json:"id"`
+	SKU         *string      `json:"sku"`
+	Title       *string      `json:"title"`
+	Description *string      `json:"description"`
+	Price       *json.Number `json:"price"`
+	Currency    *string      `json:"currency"`
+	Stock       *int         `json:"stock"`
+	Status      *string      `json:"status"`
+	PublishAt   *string      `json:"publish_at"` // RFC 3339 или catalog.PublishAtLayout
+	LowStock    *int         `json:"low_stock"`
+	Version     int          `json:"version"`
+}
+
+// overlay накладывает переданные поля на in и проверяет их правилами формы
+func (a apiProductInput) overlay(in catalog.Input) (catalog.Fields, error) {
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	set(&in.SKU, a.SKU)
+	set(&in.Title, a.Title)
+	set(&in.Description, a.Description)
+	set(&in.Currency, a.Currency)
+	set(&in.Status, a.Status)
+	if a.Price != nil {
+		in.Price = a.Price.String()
+	}
+	if a.Stock != nil {
+		in.Stock = strconv.Itoa(*a.Stock)
+	}
+	if a.LowStock != nil {
+		in.LowStock = strconv.Itoa(*a.LowStock)
+	}
+	if a.PublishAt != nil {
+		in.PublishAt = *a.PublishAt
+		if t, err := time.Parse(time.RFC3339, *a.PublishAt); err == nil {
+			in.PublishAt = catalog.FormatPublishAt(&t)
+		}
+	}
+	return in.Validate()
+}
+
+// apiBatchResult — итог по одному товару пакетной правки
+type apiBatchResult struct {
+	ID      uint   `json:"id,omitempty"`
+	SKU     string `json:"sku,omitempty"`
+	Status  string `json:"status"` // updated, unchanged, error, conflict, not_found
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// /api/v1/seller — товары продавца для его собственных систем (склад, учёт), по ключу API
+func registerSellerAPI(r *gin.Engine, db *gorm.DB) {
+	api := r.Group("/api/v1/seller")
+	read := mustAPIKey(db, models.ScopeProductsRead)
+	write := mustAPIKey(db, models.ScopeProductsWrite)
+
+	// List: ?after_id=&limit= (до 500), archived=1 — архив вместо активных
+	api.GET("/products", read, func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		afterID, _ := strconv.ParseUint(c.Query("after_id"), 10, 64)
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > apiBatchLimit {
+			apiError(c, http.StatusBadRequest, "limit must be 1..500")
+			return
+		}
+		q := db.Where("seller_id = ? AND id > ?", u.ID, afterID)
+		if c.Query("archived") == "1" {
+			q = q.Unscoped().Scopes(models.ArchivedProducts)
+		} else {
+			q = q.Scopes(models.ActiveProducts)
+		}
+		var items []models.Product
+		if err := q.Order("id").Limit(limit).Find(&items).Error; err != nil {
+			apiError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out := make([]apiProduct, 0, len(items))
+		for _, p := range items {
+			out = append(out, toAPIProduct(c, p))
+		}
+		next := uint(0)
+		if len(items) == limit {
+			next = items[len(items)-1].ID
+		}
+		c.JSON(http.StatusOK, gin.H{"items": out, "next_after_id": next})
+	})
+
+	api.GET("/products/:id", read, func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		var p models.Product
+		if err := db.First(&p, "id = ? AND seller_id = ?", c.Param("id"), u.ID).Error; err != nil {
+			apiError(c, http.StatusNotFound, "not found")
+			return
+		}
+		c.JSON(http.StatusOK, toAPIProduct(c, p))
+	})
+
+	// Create — те же правила, что у формы
+	api.POST("/products", write, func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		var body apiProductInput
+		if err := c.ShouldBindJSON(&body); err != nil {
+			apiError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		f, err := body.overlay(catalog.Input{})
+		if err == nil {
+			err = catalog.CheckSKU(db, u.ID, f.SKU, 0)
+		}
+		if err == nil {
+			err = catalog.CheckPublish(db, u.ID, f.Status)
+		}
+		if err != nil {
+			apiError(c, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		item := models.Product{SellerID: u.ID}
+		f.ApplyTo(&item)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			return models.RecordRevision(tx, nil, item, models.ProductRevision{UserID: u.ID, Action: models.RevisionAPI})
+		})
+		if err != nil {
+			apiError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusCreated, toAPIProduct(c, item))
+	})
+
+	// Batch update — каждый товар отдельно: ошибка в одном не отменяет остальные
+	api.PATCH("/products", write, func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		var body []apiProductInput
+		if err := c.ShouldBindJSON(&body); err != nil {
+			apiError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(body) == 0 || len(body) > apiBatchLimit {
+			apiError(c, http.StatusBadRequest, "send 1..500 products")
+			return
+		}
+		results := make([]apiBatchResult, 0, len(body))
+		for _, in := range body {
+			results = append(results, apiUpdate(db, u.ID, in))
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	})
+}
+
+// apiUpdate — правка одного товара из пакета через UpdateProduct с ревизией
+func apiUpdate(db *gorm.DB, sellerID uint, in apiProductInput) apiBatchResult {
+	res := apiBatchResult{ID: in.ID}
+	if in.SKU != nil {
+		res.SKU = *in.SKU
+	}
+	q := db.Where("seller_id = ?", sellerID)
+	switch {
+	case in.ID != 0:
+		q = q.Where("id = ?", in.ID)
+	case res.SKU != "":
+		q = q.Where("sku = ?", res.SKU)
+	default:
+		res.Status, res.Error = "error", "id or sku is required"
+		return res
+	}
+	var p models.Product
+	if err := q.First(&p).Error; err != nil {
+		res.Status = "not_found"
+		return res
+	}
+	res.ID, res.SKU = p.ID, p.SKU
+	// без версии правка затёрла бы чужие изменения — её надо прочитать заранее
+	if in.Version < 1 {
+		res.Status, res.Version, res.Error = "error", p.Version, "version is required, current version is "+strconv.Itoa(p.Version)
+		return res
+	}
+
+	f, err := in.overlay(catalog.FromProduct(p))
+	if err == nil {
+		err = catalog.CheckSKU(db, sellerID, f.SKU, p.ID)
+	}
+	if err == nil {
+		err = catalog.CheckPublish(db, sellerID, f.Status)
+	}
+	if err != nil {
+		res.Status, res.Error = "error", err.Error()
+		return res
+	}
+	before, after := p, p
+	f.ApplyTo(&after)
+	res.SKU, res.Version = after.SKU, p.Version
+	if len(models.SnapshotOf(before).Diff(models.SnapshotOf(after))) == 0 {
+		res.Status = "unchanged"
+		return res
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := models.UpdateProduct(tx, &after, in.Version); err != nil {
+			return err
+		}
+		return models.RecordRevision(tx, &before, after, models.ProductRevision{UserID: sellerID, Action: models.RevisionAPI})
+	})
+	switch {
+	case errors.Is(err, models.ErrVersionConflict):
+		res.Status, res.Error = "conflict", "product was changed, current version is "+strconv.Itoa(p.Version)
+	case err != nil:
+		res.Status, res.Error = "error", err.Error()
+	default:
+		res.Status, res.Version = "updated", after.Version
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// shopPageSize — товаров на странице витрины продавца
+const shopPageSize = 24
+
+// shopsFor — профили продавцов для подписи «Продавец» в карточках, корзине и заказе
+func shopsFor(db *gorm.DB, sellerIDs []uint) map[uint]*models.SellerProfile {
+	shops, err := models.ShopsOf(db, sellerIDs)
+	if err != nil {
+		log.Println("shops:", err)
+		return map[uint]*models.SellerProfile{}
+	}
+	return shops
+}
+
+// likePattern — подстрока для ILIKE с экранированными % и _
+func likePattern(q string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+}
+
+// Витрина продавца /shop/:slug и её настройка в кабинете
+func registerShopRoutes(r *gin.Engine, db *gorm.DB) {
+	r.GET("/shop/:slug", func(c *gin.Context) {
+		var shop models.SellerProfile
+		if err := db.Preload("Rating").First(&shop, "slug = ?", c.Param("slug")).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		q := strings.TrimSpace(c.Query("q"))
+		pageNo, _ := strconv.Atoi(c.Query("page"))
+		pageNo = max(pageNo, 1)
+
+		query := db.Model(&models.Product{}).Scopes(models.ListedProducts).Where("seller_id = ?", shop.SellerID)
+		if q != "" {
+			like := likePattern(q)
+			query = query.Where("title ILIKE ? OR description ILIKE ?", like, like)
+		}
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		var items []models.Product
+		if err := query.Order(catalogOrder(c.Query("sort"))).Limit(shopPageSize).Offset((pageNo - 1) * shopPageSize).Find(&items).Error; err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.HTML(http.StatusOK, "shop.tmpl", withUser(c, ViewData{
+			"Shop": shop, "Items": items, "Query": q, "Total": total, "Sort": c.Query("sort"),
+			"Page": pageNo, "Pages": int(math.Ceil(float64(total) / shopPageSize)),
+		}))
+	})
+
+	form := func(c *gin.Context, status int, shop models.SellerProfile, errMsg string) {
+		c.HTML(status, "shop_settings.tmpl", withUser(c, ViewData{"Shop": shop, "Error": errMsg, "Saved": c.Query("saved") != ""}))
+	}
+
+	// профиля может ещё не быть — тогда форма заполняется по имени продавца
+	load := func(u *models.User) (models.SellerProfile, error) {
+		shop := models.SellerProfile{SellerID: u.ID}
+		err := db.First(&shop, "seller_id = ?", u.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			shop.ShopName = u.Username
+			shop.Slug = models.Slugify(u.Username, u.ID)
+			return shop, nil
+		}
+		return shop, err
+	}
+
+	r.GET("/seller/shop", mustSeller(db), func(c *gin.Context) {
+		shop, err := load(c.MustGet("currentUser").(*models.User))
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		form(c, http.StatusOK, shop, "")
+	})
+
+	r.POST("/seller/shop", mustSeller(db), func(c *gin.Context) {
+		shop, err := load(c.MustGet("currentUser").(*models.User))
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		shop.ShopName = strings.TrimSpace(c.PostForm("shop_name"))
+		shop.Slug = strings.ToLower(strings.TrimSpace(c.PostForm("slug")))
+		shop.Description = strings.TrimSpace(c.PostForm("description"))
+		shop.Policies = strings.TrimSpace(c.PostForm("policies"))
+		if shop.ShopName == "" {
+			form(c, http.StatusBadRequest, shop, "Name your shop")
+			return
+		}
+		if !models.SlugPattern.MatchString(shop.Slug) {
+			form(c, http.StatusBadRequest, shop, "Shop address: 3–64 lowercase latin letters, digits and dashes")
+			return
+		}
+		var taken int64
+		db.Model(&models.SellerProfile{}).Where("slug = ? AND seller_id <> ?", shop.Slug, shop.SellerID).Count(&taken)
+		if taken > 0 {
+			form(c, http.StatusConflict, shop, "Address /shop/"+shop.Slug+" is taken")
+			return
+		}
+		for field, path := range map[string]*string{"logo": &shop.LogoPath, "banner": &shop.BannerPath} {
+			img, err := saveUploadedImage(c, field)
+			if err != nil {
+				form(c, http.StatusBadRequest, shop, field+": "+err.Error())
+				return
+			}
+			if img != "" {
+				*path = img
+			}
+			if c.PostForm("remove_"+field) != "" {
+				*path = ""
+			}
+		}
+		if err := db.Save(&shop).Error; err != nil {
+			form(c, http.StatusInternalServerError, shop, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/shop?saved=1")
+	})
+
+	// Vacation — пауза магазина: товары видны, но не продаются. С датой возвращения
+	// планировщик снимет паузу сам (models.EndVacations), без неё — кнопкой «I'm back».
+	r.POST("/seller/shop/vacation", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		shop, err := load(u)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if c.PostForm("action") == "end" {
+			shop.VacationFrom, shop.VacationUntil, shop.VacationNote = nil, nil, ""
+		} else {
+			now := time.Now()
+			shop.VacationUntil = nil
+			if s := strings.TrimSpace(c.PostForm("until")); s != "" {
+				t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+				if err != nil || !t.After(now) {
+					form(c, http.StatusBadRequest, shop, "Return date must be in the future")
+					return
+				}
+				shop.VacationUntil = &t
+			}
+			shop.VacationFrom = &now
+			shop.VacationNote = strings.TrimSpace(c.PostForm("vacation_note"))
+		}
+		// профиля ещё не было: адрес по имени продавца может быть занят другим магазином
+		if shop.ID == 0 {
+			var taken int64
+			db.Model(&models.SellerProfile{}).Where("slug = ?", shop.Slug).Count(&taken)
+			if taken > 0 {
+				shop.Slug = fmt.Sprintf("shop-%d", u.ID)
+			}
+		}
+		if err := db.Save(&shop).Error; err != nil {
+			form(c, http.StatusInternalServerError, shop, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/shop?saved=1")
+	})
+}
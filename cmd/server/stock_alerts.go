@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+)
+
+// Подписка покупателя на поступление товара (уведомление уходит из models.stockChanged)
+func registerStockAlertRoutes(r *gin.Engine, db *gorm.DB) {
+	r.POST("/product/:id/notify", func(c *gin.Context) {
+		u, err := sessionUser(c, db)
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/login")
+			return
+		}
+		var p models.Product
+		if err := db.Scopes(models.ListedProducts).First(&p, "id = ?", c.Param("id")).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+		if p.Stock > 0 {
+			c.Redirect(http.StatusSeeOther, fmt.Sprintf("/product/%d", p.ID))
+			return
+		}
+		// повторная подписка после уже полученного уведомления снова ждёт поступления
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}, {Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"notified_at": nil}),
+		}).Create(&models.StockSubscription{ProductID: p.ID, UserID: u.ID}).Error
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/product/%d?subscribed=1", p.ID))
+	})
+}
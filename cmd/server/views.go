@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin/render"
+)
+
+// pageRender — отдельный набор шаблонов на каждую страницу (layouts + сама страница).
+// С одним общим LoadHTMLGlob блоки "title"/"content" разных страниц перетирают друг друга.
+type pageRender map[string]*template.Template
+
+// loadPages парсит internal/views/**/*.tmpl; имя страницы — имя файла ("cart.tmpl")
+func loadPages(dir string, funcs template.FuncMap) (pageRender, error) {
+	layouts, err := filepath.Glob(filepath.Join(dir, "layouts", "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	pages, err := filepath.Glob(filepath.Join(dir, "*", "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	out := pageRender{}
+	for _, page := range pages {
+		if filepath.Dir(page) == filepath.Join(dir, "layouts") {
+			continue
+		}
+		name := filepath.Base(page)
+		if _, dup := out[name]; dup {
+			return nil, fmt.Errorf("duplicate template name %s", name)
+		}
+		t, err := template.New(name).Funcs(funcs).ParseFiles(append([]string{page}, layouts...)...)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = t
+	}
+	return out, nil
+}
+
+func (p pageRender) Instance(name string, data any) render.Render {
+	t, ok := p[name]
+	if !ok {
+		t = template.New(name) // пустой шаблон: Execute вернёт ошибку вместо паники
+	}
+	return render.HTML{Template: t, Name: name, Data: data}
+}
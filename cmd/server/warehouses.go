@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+)
+
+// warehouseRow — склад и сколько на нём всего единиц товара
+type warehouseRow struct {
+	models.Warehouse
+	Units int
+}
+
+var errWarehouseNotEmpty = errors.New("warehouse is not empty")
+
+// Склады продавца: список, создание, правка названия/адреса/приоритета, удаление пустого склада
+func registerWarehouseRoutes(r *gin.Engine, db *gorm.DB) {
+	page := func(c *gin.Context, status int, errMsg string) {
+		u := c.MustGet("currentUser").(*models.User)
+		var rows []warehouseRow
+		err := db.Model(&models.Warehouse{}).
+			Select("warehouses.*, COALESCE(SUM(stock_levels.qty), 0) AS units").
+			Joins("LEFT JOIN stock_levels ON stock_levels.warehouse_id = warehouses.id").
+			Where("warehouses.seller_id = ?", u.ID).
+			Group("warehouses.id").
+			Order("warehouses.priority, warehouses.id").
+			Scan(&rows).Error
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.HTML(status, "warehouses.tmpl", withUser(c, ViewData{"Warehouses": rows, "Error": errMsg}))
+	}
+
+	// form читает поля склада; пустое название — ошибка
+	form := func(c *gin.Context, w *models.Warehouse) bool {
+		w.Name = strings.TrimSpace(c.PostForm("name"))
+		w.Address = strings.TrimSpace(c.PostForm("address"))
+		prio, err := strconv.Atoi(strings.TrimSpace(c.DefaultPostForm("priority", "0")))
+		if w.Name == "" || err != nil {
+			page(c, http.StatusBadRequest, "Name the warehouse; priority is a whole number (lower ships first)")
+			return false
+		}
+		w.Priority = prio
+		return true
+	}
+
+	own := func(c *gin.Context) (*models.Warehouse, bool) {
+		u := c.MustGet("currentUser").(*models.User)
+		var w models.Warehouse
+		if err := db.First(&w, "id = ? AND seller_id = ?", c.Param("id"), u.ID).Error; err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return nil, false
+		}
+		return &w, true
+	}
+
+	r.GET("/seller/warehouses", mustSeller(db), func(c *gin.Context) {
+		page(c, http.StatusOK, "")
+	})
+
+	r.POST("/seller/warehouses", mustSeller(db), func(c *gin.Context) {
+		u := c.MustGet("currentUser").(*models.User)
+		w := models.Warehouse{SellerID: u.ID}
+		if !form(c, &w) {
+			return
+		}
+		if err := db.Create(&w).Error; err != nil {
+			page(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/warehouses")
+	})
+
+	r.POST("/seller/warehouses/:id", mustSeller(db), func(c *gin.Context) {
+		w, ok := own(c)
+		if !ok || !form(c, w) {
+			return
+		}
+		if err := db.Model(w).Select("name", "address", "priority").Updates(w).Error; err != nil {
+			page(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/warehouses")
+	})
+
+	// удалить можно только пустой склад — остаток сначала перемещают на другой
+	r.POST("/seller/warehouses/:id/delete", mustSeller(db), func(c *gin.Context) {
+		w, ok := own(c)
+		if !ok {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// склад и его остатки блокируются до конца удаления: параллельная продажа, перемещение
+			// или поступление (оно ждёт строку склада, см. models.addLevel) не изменят их между проверкой и удалением
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Warehouse{}, w.ID).Error; err != nil {
+				return err
+			}
+			var levels []models.StockLevel
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("warehouse_id = ?", w.ID).Find(&levels).Error; err != nil {
+				return err
+			}
+			for _, l := range levels {
+				if l.Qty > 0 {
+					return errWarehouseNotEmpty
+				}
+			}
+			if err := tx.Where("warehouse_id = ? AND qty = 0", w.ID).Delete(&models.StockLevel{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(w).Error
+		})
+		if errors.Is(err, errWarehouseNotEmpty) {
+			page(c, http.StatusConflict, "Move the stock out of "+w.Name+" before deleting it")
+			return
+		}
+		if err != nil {
+			page(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/seller/warehouses")
+	})
+}
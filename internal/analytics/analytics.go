@@ -0,0 +1,184 @@
+// Package analytics — агрегаты продаж и просмотров для кабинета продавца.
+// Всё считается SQL-запросами по order_items/orders (за вычетом возвратов по спорам) и дневным счётчикам просмотров.
+package analytics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// LowStockThreshold — при каком остатке товар попадает в предупреждения, если продавец не задал свой порог
+const LowStockThreshold = 5
+
+// RecordView увеличивает дневной счётчик просмотров товара
+func RecordView(db *gorm.DB, productID uint, now time.Time) error {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]any{"views": gorm.Expr("product_view_stats.views + 1")}),
+	}).Create(&models.ProductViewStat{ProductID: productID, Day: day, Views: 1}).Error
+}
+
+// Summary — итоги продавца за период
+type Summary struct {
+	Revenue    []money.Money // по валютам заказов (обычно одна — валюта расчётов)
+	Orders     int64
+	Units      int64
+	Views      int64
+	Conversion float64 // заказы / просмотры, в процентах
+}
+
+// Выручка и штуки позиции за вычетом возвратов по спорам (refunds из sales).
+// Возврат вычитается из дня продажи, а не дня решения спора: так итоги прошлых периодов не уходят в минус.
+const (
+	netUnits   = "order_items.qty - COALESCE(refunds.qty, 0)"
+	netRevenue = "order_items.unit_amount * order_items.qty - COALESCE(refunds.amount, 0)"
+)
+
+// sales — позиции продавца из заказов-продаж начиная с from, с суммой и штуками возвратов
+func sales(db *gorm.DB, sellerID uint, from time.Time) *gorm.DB {
+	refunds := db.Model(&models.Dispute{}).
+		Select("order_item_id, SUM(qty) AS qty, SUM(refund_amount) AS amount").
+		Where("outcome = ?", models.OutcomeRefund).
+		Group("order_item_id")
+	return db.Table("order_items").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("LEFT JOIN (?) refunds ON refunds.order_item_id = order_items.id", refunds).
+		Where("order_items.seller_id = ? AND orders.status IN ? AND orders.created_at >= ?", sellerID, models.SaleStatuses, from)
+}
+
+// SellerSummary — выручка, заказы, штуки, просмотры и конверсия за период
+func SellerSummary(db *gorm.DB, sellerID uint, from time.Time) (Summary, error) {
+	var s Summary
+	var rev []struct {
+		Currency money.Currency
+		Amount   int64
+	}
+	err := sales(db, sellerID, from).
+		Select("orders.currency AS currency, SUM(" + netRevenue + ") AS amount").
+		Group("orders.currency").Order("amount DESC").
+		Scan(&rev).Error
+	if err != nil {
+		return s, err
+	}
+	for _, r := range rev {
+		s.Revenue = append(s.Revenue, money.New(r.Amount, r.Currency))
+	}
+
+	var tot struct{ Orders, Units int64 }
+	err = sales(db, sellerID, from).
+		Select("COUNT(DISTINCT order_items.order_id) AS orders, COALESCE(SUM(" + netUnits + "), 0) AS units").
+		Scan(&tot).Error
+	if err != nil {
+		return s, err
+	}
+	s.Orders, s.Units = tot.Orders, tot.Units
+
+	err = db.Table("product_view_stats").
+		Joins("JOIN products ON products.id = product_view_stats.product_id").
+		Where("products.seller_id = ? AND product_view_stats.day >= ?", sellerID, from.Format("2006-01-02")).
+		Select("COALESCE(SUM(product_view_stats.views), 0)").
+		Scan(&s.Views).Error
+	if err != nil {
+		return s, err
+	}
+	s.Conversion = percent(s.Orders, s.Views)
+	return s, nil
+}
+
+func percent(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) * 100 / float64(b)
+}
+
+// DaySales — точка дневного графика
+type DaySales struct {
+	Day      time.Time
+	Units    int64
+	Revenue  int64
+	Currency money.Currency
+}
+
+// Daily — продажи по дням (только дни, где были продажи)
+func Daily(db *gorm.DB, sellerID uint, from time.Time) ([]DaySales, error) {
+	var out []DaySales
+	err := sales(db, sellerID, from).
+		Select("DATE(orders.created_at) AS day, orders.currency AS currency, SUM(" + netUnits + ") AS units, SUM(" + netRevenue + ") AS revenue").
+		Group("DATE(orders.created_at), orders.currency").
+		Order("day").
+		Scan(&out).Error
+	return out, err
+}
+
+// TopProduct — строка рейтинга товаров продавца
+type TopProduct struct {
+	ProductID  uint
+	Title      string
+	Units      int64
+	Orders     int64
+	Revenue    int64
+	Currency   money.Currency
+	Views      int64
+	Conversion float64
+}
+
+// Amount — выручка товара
+func (t TopProduct) Amount() money.Money { return money.New(t.Revenue, t.Currency) }
+
+// TopProducts — самые продаваемые товары по выручке, с просмотрами и конверсией
+func TopProducts(db *gorm.DB, sellerID uint, from time.Time, limit int) ([]TopProduct, error) {
+	var out []TopProduct
+	err := sales(db, sellerID, from).
+		Select(`order_items.product_id AS product_id, MAX(order_items.title) AS title, orders.currency AS currency,
+			SUM(` + netUnits + `) AS units, COUNT(DISTINCT order_items.order_id) AS orders,
+			SUM(` + netRevenue + `) AS revenue`).
+		Group("order_items.product_id, orders.currency").
+		Order("revenue DESC").
+		Limit(limit).
+		Scan(&out).Error
+	if err != nil || len(out) == 0 {
+		return out, err
+	}
+	ids := make([]uint, len(out))
+	for i, t := range out {
+		ids[i] = t.ProductID
+	}
+	var views []struct {
+		ProductID uint
+		Views     int64
+	}
+	err = db.Table("product_view_stats").
+		Where("product_id IN ? AND day >= ?", ids, from.Format("2006-01-02")).
+		Select("product_id, SUM(views) AS views").
+		Group("product_id").
+		Scan(&views).Error
+	if err != nil {
+		return out, err
+	}
+	byID := map[uint]int64{}
+	for _, v := range views {
+		byID[v.ProductID] = v.Views
+	}
+	for i := range out {
+		out[i].Views = byID[out[i].ProductID]
+		out[i].Conversion = percent(out[i].Orders, out[i].Views)
+	}
+	return out, nil
+}
+
+// LowStock — активные товары продавца с остатком не выше порога
+func LowStock(db *gorm.DB, sellerID uint) ([]models.Product, error) {
+	var out []models.Product
+	err := db.Scopes(models.ActiveProducts).
+		Where("seller_id = ? AND stock <= CASE WHEN low_stock > 0 THEN low_stock ELSE ? END", sellerID, LowStockThreshold).
+		Order("stock, id").
+		Find(&out).Error
+	return out, err
+}
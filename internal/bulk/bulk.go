@@ -0,0 +1,314 @@
+// Package bulk — массовые действия над товарами продавца: предпросмотр, применение
+// одной транзакцией и отмена в течение UndoWindow.
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// Action — массовое действие
+type Action string
+
+const (
+	PricePercent Action = "price_percent" // цена ± процент
+	PriceAmount  Action = "price_amount"  // цена ± сумма в валюте товара
+	SetStock     Action = "stock"
+	Publish      Action = "publish"
+	Unpublish    Action = "unpublish"
+	SetCategory  Action = "category" // Value — id категории, "" — без категории
+	Archive      Action = "archive"
+	Delete       Action = "delete"
+)
+
+// Actions — действия в порядке показа
+var Actions = []Action{PricePercent, PriceAmount, SetStock, Publish, Unpublish, SetCategory, Archive, Delete}
+
+// Valid — известное ли действие
+func (a Action) Valid() bool {
+	for _, x := range Actions {
+		if a == x {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoWindow — сколько после применения действие можно отменить
+const UndoWindow = 15 * time.Minute
+
+// MaxProducts — сколько товаров можно выбрать за раз
+const MaxProducts = 500
+
+// ErrChanged — товары изменились после предпросмотра: нужно посмотреть заново
+var ErrChanged = errors.New("some products were changed after the preview")
+
+// Row — строка предпросмотра: товар, каким станет, и что поменяется. Error — строка будет пропущена.
+type Row struct {
+	Product models.Product
+	After   models.Product
+	Changes []models.FieldChange
+	Error   string
+}
+
+// Plan считает, что сделает действие с выбранными товарами продавца. Ничего не пишет.
+func Plan(db *gorm.DB, sellerID uint, ids []uint, action Action, value string) ([]Row, error) {
+	if !action.Valid() {
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("select products first")
+	}
+	if len(ids) > MaxProducts {
+		return nil, fmt.Errorf("select at most %d products", MaxProducts)
+	}
+	change, err := parse(db, action, value)
+	if err != nil {
+		return nil, err
+	}
+	if action == Publish {
+		if err := catalog.CheckPublish(db, sellerID, models.StatusPublished); err != nil {
+			return nil, err
+		}
+	}
+	var list []models.Product
+	if err := db.Scopes(models.ActiveProducts).Where("seller_id = ? AND id IN ?", sellerID, ids).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(list))
+	for _, p := range list {
+		row := Row{Product: p, After: p}
+		switch action {
+		case Archive:
+			row.Changes = []models.FieldChange{{Field: "archived", Old: "no", New: "yes"}}
+		case Delete:
+			row.Changes = []models.FieldChange{{Field: "deleted", Old: "no", New: "yes"}}
+		default:
+			if err := change(&row.After); err != nil {
+				row.Error = err.Error()
+			} else {
+				row.Changes = models.SnapshotOf(p).Diff(models.SnapshotOf(row.After))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parse проверяет параметр действия один раз и возвращает правку одного товара
+func parse(db *gorm.DB, action Action, value string) (func(*models.Product) error, error) {
+	value = strings.TrimSpace(value)
+	switch action {
+	case PricePercent:
+		pct, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+		if err != nil || pct <= -100 || pct > 1000 || math.IsNaN(pct) {
+			return nil, fmt.Errorf("percent must be a number above -100, e.g. -10 or 5.5")
+		}
+		return func(p *models.Product) error {
+			cents := math.Round(float64(p.PriceCents) * (100 + pct) / 100)
+			if cents < 1 {
+				return fmt.Errorf("price would drop to zero")
+			}
+			p.PriceCents = int(cents)
+			return nil
+		}, nil
+	case PriceAmount:
+		sign := int64(1)
+		abs := value
+		if rest, ok := strings.CutPrefix(value, "-"); ok {
+			sign, abs = -1, rest
+		} else {
+			abs = strings.TrimPrefix(value, "+")
+		}
+		if abs == "" {
+			return nil, fmt.Errorf("enter an amount, e.g. -100 or 50.50")
+		}
+		return func(p *models.Product) error {
+			// сумма в валюте каждого товара
+			m, err := money.Parse(abs, p.Currency)
+			if err != nil {
+				return err
+			}
+			cents := int64(p.PriceCents) + sign*m.Amount
+			if cents < 1 {
+				return fmt.Errorf("price would drop to zero or below")
+			}
+			p.PriceCents = int(cents)
+			return nil
+		}, nil
+	case SetStock:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("stock must be a whole number, 0 or more")
+		}
+		return func(p *models.Product) error {
+			p.Stock = n
+			return nil
+		}, nil
+	case Publish:
+		return func(p *models.Product) error {
+			p.Status, p.PublishAt = models.StatusPublished, nil
+			return nil
+		}, nil
+	case Unpublish:
+		return func(p *models.Product) error {
+			p.Status, p.PublishAt = models.StatusUnpublished, nil
+			return nil
+		}, nil
+	case SetCategory:
+		var id *uint
+		if value != "" && value != "0" {
+			var cat models.Category
+			if err := db.First(&cat, "id = ?", value).Error; err != nil {
+				return nil, fmt.Errorf("unknown category")
+			}
+			id = &cat.ID
+		}
+		return func(p *models.Product) error {
+			p.CategoryID = id
+			return nil
+		}, nil
+	}
+	return func(*models.Product) error { return nil }, nil
+}
+
+// Apply применяет действие одной транзакцией. versions — версии товаров из предпросмотра:
+// если какой-то товар с тех пор изменили, не применяется ничего (ErrChanged).
+// Строки с ошибками пропускаются, как и показывал предпросмотр.
+func Apply(db *gorm.DB, sellerID uint, versions map[uint]int, action Action, value string) (*models.BulkEdit, error) {
+	ids := make([]uint, 0, len(versions))
+	for id := range versions {
+		ids = append(ids, id)
+	}
+	edit := &models.BulkEdit{SellerID: sellerID, Action: string(action), Value: value}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rows, err := Plan(tx, sellerID, ids, action, value)
+		if err != nil {
+			return err
+		}
+		if len(rows) != len(ids) {
+			return ErrChanged // что-то успели архивировать или удалить
+		}
+		now := time.Now()
+		for _, row := range rows {
+			p := row.Product
+			if versions[p.ID] != p.Version {
+				return ErrChanged
+			}
+			if row.Error != "" || len(row.Changes) == 0 {
+				continue
+			}
+			item := models.BulkEditItem{ProductID: p.ID, Before: models.SnapshotOf(p), Version: p.Version}
+			switch action {
+			case Archive:
+				err = tx.Model(&models.Product{}).Where("id = ?", p.ID).Update("archived_at", now).Error
+			case Delete:
+				err = tx.Delete(&models.Product{}, p.ID).Error
+			default:
+				after := row.After
+				if err = models.UpdateProduct(tx, &after, p.Version); err == nil {
+					item.Version = after.Version
+					err = models.RecordRevision(tx, &p, after, models.ProductRevision{UserID: sellerID, Action: models.RevisionBulk})
+				}
+			}
+			if errors.Is(err, models.ErrVersionConflict) {
+				return ErrChanged
+			}
+			if err != nil {
+				return err
+			}
+			edit.Items = append(edit.Items, item)
+		}
+		if len(edit.Items) == 0 {
+			return fmt.Errorf("nothing to change")
+		}
+		return tx.Create(edit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// Undoable — можно ли ещё отменить действие
+func Undoable(e models.BulkEdit) bool {
+	return e.UndoneAt == nil && time.Since(e.CreatedAt) < UndoWindow
+}
+
+// Undo отменяет массовое действие. Товары, изменённые после него, не трогаем — они в skipped.
+func Undo(db *gorm.DB, sellerID, editID uint) (restored, skipped int, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var e models.BulkEdit
+		if err := tx.First(&e, "id = ? AND seller_id = ?", editID, sellerID).Error; err != nil {
+			return err
+		}
+		if !Undoable(e) {
+			return fmt.Errorf("this action can no longer be undone")
+		}
+		// условный UPDATE: двойное нажатие не отменит дважды
+		res := tx.Model(&models.BulkEdit{}).Where("id = ? AND undone_at IS NULL", e.ID).Update("undone_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("this action can no longer be undone")
+		}
+
+		for _, it := range e.Items {
+			var p models.Product
+			if err := tx.Unscoped().First(&p, "id = ? AND seller_id = ?", it.ProductID, sellerID).Error; err != nil {
+				skipped++
+				continue
+			}
+			if p.Version != it.Version {
+				skipped++
+				continue
+			}
+			switch Action(e.Action) {
+			case Archive:
+				if p.ArchivedAt == nil || p.DeletedAt.Valid {
+					skipped++
+					continue
+				}
+				if err := tx.Model(&p).Update("archived_at", nil).Error; err != nil {
+					return err
+				}
+			case Delete:
+				if !p.DeletedAt.Valid {
+					skipped++
+					continue
+				}
+				if err := tx.Unscoped().Model(&p).Update("deleted_at", nil).Error; err != nil {
+					return err
+				}
+			default:
+				before := p
+				it.Before.ApplyTo(&p)
+				p.Stock = it.Before.Stock // версия та же — с массового действия продаж не было
+				if err := models.UpdateProduct(tx, &p, it.Version); err != nil {
+					if errors.Is(err, models.ErrVersionConflict) {
+						skipped++
+						continue
+					}
+					return err
+				}
+				if err := models.RecordRevision(tx, &before, p, models.ProductRevision{UserID: sellerID, Action: models.RevisionUndo}); err != nil {
+					return err
+				}
+			}
+			restored++
+		}
+		return nil
+	})
+	return restored, skipped, err
+}
@@ -0,0 +1,191 @@
+// Package catalog — правила проверки товара, общие для формы продавца, импорта и API.
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// PublishAtLayout — формат <input type="datetime-local"> и колонки publish_at в файлах
+const PublishAtLayout = "2006-01-02T15:04"
+
+// MaxSKU — максимальная длина артикула продавца
+const MaxSKU = 64
+
+// Input — поля товара как их ввели (строки формы, ячейки файла импорта)
+type Input struct {
+	SKU         string
+	Title       string
+	Description string
+	Price       string
+	Currency    string
+	Stock       string
+	Status      string
+	PublishAt   string
+	LowStock    string // порог уведомления об остатке; пусто — не следить
+}
+
+// FromProduct — Input с текущими значениями товара: основа для частичного обновления
+func FromProduct(p models.Product) Input {
+	return Input{
+		SKU:         p.SKU,
+		Title:       p.Title,
+		Description: p.Description,
+		Price:       p.Price().Decimal(),
+		Currency:    string(p.Currency),
+		Stock:       strconv.Itoa(p.Stock),
+		Status:      string(p.Status),
+		PublishAt:   FormatPublishAt(p.PublishAt),
+		LowStock:    formatLowStock(p.LowStock),
+	}
+}
+
+func formatLowStock(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// Form — значения для повторного показа формы (ключи как в seller_form.tmpl)
+func (in Input) Form() map[string]any {
+	return map[string]any{
+		"SKU": in.SKU, "Title": in.Title, "Description": in.Description,
+		"Price": in.Price, "Currency": in.Currency, "Stock": in.Stock,
+		"Status": in.Status, "PublishAt": in.PublishAt, "LowStock": in.LowStock,
+	}
+}
+
+// Fields — поля товара после проверки
+type Fields struct {
+	SKU         string
+	Title       string
+	Description string
+	Price       money.Money
+	Stock       int
+	Status      models.ListingStatus
+	PublishAt   *time.Time
+	LowStock    int
+}
+
+// Validate — правила POST /seller/products; ими же проверяются правка, импорт и API
+func (in Input) Validate() (Fields, error) {
+	f := Fields{
+		SKU:         strings.TrimSpace(in.SKU),
+		Title:       strings.TrimSpace(in.Title),
+		Description: strings.TrimSpace(in.Description),
+	}
+	price, stock := strings.TrimSpace(in.Price), strings.TrimSpace(in.Stock)
+	if f.Title == "" || price == "" || stock == "" {
+		return f, fmt.Errorf("fill title, price, stock")
+	}
+	if len(f.SKU) > MaxSKU {
+		return f, fmt.Errorf("SKU is longer than %d characters", MaxSKU)
+	}
+	cur := money.Currency(strings.ToUpper(strings.TrimSpace(in.Currency)))
+	if !cur.Known() {
+		return f, fmt.Errorf("unknown currency %q", in.Currency)
+	}
+	p, err := money.Parse(price, cur)
+	if err != nil {
+		return f, fmt.Errorf("invalid price %v", err)
+	}
+	if p.Amount <= 0 {
+		return f, fmt.Errorf("price must be positive")
+	}
+	f.Price = p
+	if f.Stock, err = strconv.Atoi(stock); err != nil || f.Stock < 0 {
+		return f, fmt.Errorf("stock must be a whole number, 0 or more")
+	}
+	if f.Status, f.PublishAt, err = parseListing(strings.TrimSpace(in.Status), strings.TrimSpace(in.PublishAt)); err != nil {
+		return f, err
+	}
+	if s := strings.TrimSpace(in.LowStock); s != "" {
+		if f.LowStock, err = strconv.Atoi(s); err != nil || f.LowStock < 0 {
+			return f, fmt.Errorf("low stock alert must be a whole number, 0 or more")
+		}
+	}
+	return f, nil
+}
+
+// ApplyTo переносит проверенные поля в товар
+func (f Fields) ApplyTo(p *models.Product) {
+	p.SKU = f.SKU
+	p.Title = f.Title
+	p.Description = f.Description
+	p.PriceCents = int(f.Price.Amount)
+	p.Currency = f.Price.Currency
+	p.Stock = f.Stock
+	p.Status = f.Status
+	p.PublishAt = f.PublishAt
+	p.LowStock = f.LowStock
+}
+
+// parseListing проверяет статус объявления; для scheduled нужна дата публикации в будущем
+func parseListing(status, publishAt string) (models.ListingStatus, *time.Time, error) {
+	st := models.ListingStatus(status)
+	if st == "" {
+		st = models.StatusPublished
+	}
+	if !st.Valid() {
+		return "", nil, fmt.Errorf("unknown status %q", status)
+	}
+	if st != models.StatusScheduled {
+		return st, nil, nil
+	}
+	t, err := time.ParseInLocation(PublishAtLayout, publishAt, time.Local)
+	if err != nil {
+		return "", nil, fmt.Errorf("set publish date and time")
+	}
+	if !t.After(time.Now()) {
+		return "", nil, fmt.Errorf("publish time must be in the future")
+	}
+	return st, &t, nil
+}
+
+// FormatPublishAt — дата публикации в формате PublishAtLayout ("" если не задана)
+func FormatPublishAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(time.Local).Format(PublishAtLayout)
+}
+
+// CheckPublish — пока продавец не прошёл проверку, он сохраняет только черновики и снятые с витрины товары
+func CheckPublish(db *gorm.DB, sellerID uint, status models.ListingStatus) error {
+	if status != models.StatusPublished && status != models.StatusScheduled {
+		return nil
+	}
+	ok, err := models.SellerVerified(db, sellerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: save it as a draft until the seller account is verified", models.ErrUnverified)
+	}
+	return nil
+}
+
+// CheckSKU — ошибка, если у продавца уже есть другой товар (в т.ч. удалённый) с таким артикулом
+func CheckSKU(db *gorm.DB, sellerID uint, sku string, exceptID uint) error {
+	if sku == "" {
+		return nil
+	}
+	var other models.Product
+	err := db.Unscoped().Where("seller_id = ? AND sku = ? AND id <> ?", sellerID, sku, exceptID).First(&other).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("SKU %q is already used by product #%d", sku, other.ID)
+}
@@ -0,0 +1,405 @@
+// Package commerceml — обмен с 1С по протоколу CommerceML 2: приём каталога (import.xml)
+// и предложений (offers.xml), выгрузка заказов. Файлы разбираются потоково, элемент за элементом.
+package commerceml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// Dir — куда складываются файлы от 1С, по папке на продавца (наружу не раздаётся)
+const Dir = "exchange"
+
+// FileLimit — размер одной части файла, который 1С присылает за запрос (mode=init)
+const FileLimit = 10 << 20
+
+// MaxFileSize и MaxSessionSize — предел собранного из частей файла и всех файлов сеанса
+// выгрузки (XML и картинки), чтобы 1С или чужой логин не заполнили диск
+const (
+	MaxFileSize    = 256 << 20
+	MaxSessionSize = 1 << 30
+)
+
+// Result — итог разбора одного файла
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Skipped   int // товара нет у нас, он удалён или строка некорректна
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged, %d skipped", r.Created, r.Updated, r.Unchanged, r.Skipped)
+}
+
+// Options — настройки обмена
+type Options struct {
+	SellerID uint
+	// PriceType — название типа цен 1С («Розничная»); пусто — первая цена предложения
+	PriceType string
+}
+
+// xmlGood — <Товар> из import.xml
+type xmlGood struct {
+	ID          string `xml:"Ид"`
+	Article     string `xml:"Артикул"`
+	Name        string `xml:"Наименование"`
+	Description string `xml:"Описание"`
+	Status      string `xml:"Статус,attr"` // "Удален" в схемах 2.05+
+}
+
+// xmlPriceType — <ТипЦены> из offers.xml
+type xmlPriceType struct {
+	ID       string `xml:"Ид"`
+	Name     string `xml:"Наименование"`
+	Currency string `xml:"Валюта"`
+}
+
+// xmlOffer — <Предложение> из offers.xml
+type xmlOffer struct {
+	ID     string `xml:"Ид"`
+	Prices []struct {
+		TypeID   string `xml:"ИдТипаЦены"`
+		Value    string `xml:"ЦенаЗаЕдиницу"`
+		Currency string `xml:"Валюта"`
+	} `xml:"Цены>Цена"`
+	Quantity string `xml:"Количество"`
+	Rests    []struct {
+		Quantity string `xml:"Количество"`
+		Stores   []struct {
+			Quantity string `xml:"Количество"`
+		} `xml:"Склад"`
+	} `xml:"Остатки>Остаток"` // схема 2.08+
+	Stores []struct {
+		Quantity string `xml:"КоличествоНаСкладе,attr"`
+	} `xml:"Склад"`
+}
+
+// ImportFile применяет import.xml или offers.xml (в новых версиях 1С — import0_1.xml, offers0_1.xml).
+// Что внутри, определяется по содержимому, а не по имени. Всё пишется одной транзакцией.
+func ImportFile(db *gorm.DB, path string, opt Options) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	var res Result
+	err = db.Transaction(func(tx *gorm.DB) error {
+		s := &syncer{tx: tx, opt: opt, res: &res, priceTypes: map[string]xmlPriceType{}, offers: map[string]*offer{}}
+		if err := s.decode(f); err != nil {
+			return err
+		}
+		return s.applyOffers()
+	})
+	return res, err
+}
+
+// offer — остаток и цена товара, сведённые по всем его характеристикам (Ид вида "товар#характеристика")
+type offer struct {
+	price    string
+	currency string
+	qty      int
+	hasQty   bool
+}
+
+type syncer struct {
+	tx         *gorm.DB
+	opt        Options
+	res        *Result
+	priceTypes map[string]xmlPriceType
+	offers     map[string]*offer
+	order      []string // Ид предложений в порядке файла
+}
+
+func (s *syncer) decode(r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("xml: %w", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "Документ":
+			// заказы в файлах каталога не ждём
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+		case "Товар":
+			var g xmlGood
+			if err := dec.DecodeElement(&g, &el); err != nil {
+				return err
+			}
+			if err := s.good(g); err != nil {
+				return err
+			}
+		case "ТипЦены":
+			var pt xmlPriceType
+			if err := dec.DecodeElement(&pt, &el); err != nil {
+				return err
+			}
+			s.priceTypes[pt.ID] = pt
+		case "Предложение":
+			var o xmlOffer
+			if err := dec.DecodeElement(&o, &el); err != nil {
+				return err
+			}
+			s.collect(o)
+		}
+	}
+}
+
+// find ищет товар продавца по Ид 1С, включая удалённые (found, но DeletedAt.Valid)
+func (s *syncer) find(externalID string) (*models.Product, error) {
+	var p models.Product
+	err := s.tx.Unscoped().Where("seller_id = ? AND external_id = ?", s.opt.SellerID, externalID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// save записывает изменения товара через UpdateProduct с ревизией; false — менять нечего
+func (s *syncer) save(before, after models.Product) (bool, error) {
+	if before.ExternalID == after.ExternalID && len(models.SnapshotOf(before).Diff(models.SnapshotOf(after))) == 0 {
+		return false, nil
+	}
+	if err := models.UpdateProduct(s.tx, &after, before.Version); err != nil {
+		return false, fmt.Errorf("product #%d: %w", before.ID, err)
+	}
+	rev := models.ProductRevision{UserID: s.opt.SellerID, Action: models.RevisionExchange}
+	return true, models.RecordRevision(s.tx, &before, after, rev)
+}
+
+// good — товар из каталога: создать (черновиком, пока нет цены) или обновить название, описание, артикул
+func (s *syncer) good(g xmlGood) error {
+	g.ID = strings.TrimSpace(g.ID)
+	g.Name = strings.TrimSpace(g.Name)
+	g.Article = strings.TrimSpace(g.Article)
+	if g.ID == "" || g.Name == "" || g.Status == "Удален" {
+		s.res.Skipped++
+		return nil
+	}
+	p, err := s.find(g.ID)
+	if err != nil {
+		return err
+	}
+	// товар уже заведён у нас вручную или импортом — связываем по артикулу
+	if p == nil && g.Article != "" {
+		var bySKU models.Product
+		err := s.tx.Unscoped().Where("seller_id = ? AND sku = ? AND external_id = ''", s.opt.SellerID, g.Article).First(&bySKU).Error
+		if err == nil {
+			p = &bySKU
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	if p != nil && p.DeletedAt.Valid {
+		s.res.Skipped++
+		return nil
+	}
+
+	sku := g.Article
+	if len(sku) > catalog.MaxSKU {
+		sku = ""
+	}
+	if sku != "" {
+		var exceptID uint
+		if p != nil {
+			exceptID = p.ID
+		}
+		if catalog.CheckSKU(s.tx, s.opt.SellerID, sku, exceptID) != nil {
+			sku = "" // артикул занят другим товаром — оставляем как было
+		}
+	}
+
+	if p == nil {
+		item := models.Product{
+			SellerID:    s.opt.SellerID,
+			ExternalID:  g.ID,
+			SKU:         sku,
+			Title:       g.Name,
+			Description: strings.TrimSpace(g.Description),
+			Currency:    money.RUB,
+			Status:      models.StatusDraft,
+		}
+		if err := s.tx.Create(&item).Error; err != nil {
+			return fmt.Errorf("good %s: %w", g.ID, err)
+		}
+		s.res.Created++
+		rev := models.ProductRevision{UserID: s.opt.SellerID, Action: models.RevisionExchange}
+		return models.RecordRevision(s.tx, nil, item, rev)
+	}
+
+	after := *p
+	after.ExternalID = g.ID
+	after.Title = g.Name
+	if d := strings.TrimSpace(g.Description); d != "" {
+		after.Description = d
+	}
+	if sku != "" {
+		after.SKU = sku
+	}
+	changed, err := s.save(*p, after)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.res.Updated++
+	} else {
+		s.res.Unchanged++
+	}
+	return nil
+}
+
+// collect запоминает предложение; применяются они после разбора файла, когда известны все характеристики
+func (s *syncer) collect(o xmlOffer) {
+	id, _, _ := strings.Cut(strings.TrimSpace(o.ID), "#")
+	if id == "" {
+		s.res.Skipped++
+		return
+	}
+	acc, ok := s.offers[id]
+	if !ok {
+		acc = &offer{}
+		s.offers[id] = acc
+		s.order = append(s.order, id)
+	}
+	if acc.price == "" {
+		for _, pr := range o.Prices {
+			pt := s.priceTypes[pr.TypeID]
+			if s.opt.PriceType != "" && !strings.EqualFold(pt.Name, s.opt.PriceType) {
+				continue
+			}
+			acc.price = strings.TrimSpace(pr.Value)
+			acc.currency = pr.Currency
+			if acc.currency == "" {
+				acc.currency = pt.Currency
+			}
+			break
+		}
+	}
+	add := func(q string) {
+		if n, ok := quantity(q); ok {
+			acc.qty += n
+			acc.hasQty = true
+		}
+	}
+	switch {
+	case o.Quantity != "":
+		add(o.Quantity)
+	case len(o.Rests) > 0:
+		// в Остатке либо остатки по складам, либо общее Количество; если есть и то и другое,
+		// общее — сумма складов, и сложить их значило бы посчитать товар дважды
+		for _, r := range o.Rests {
+			stored := acc.hasQty
+			acc.hasQty = false
+			for _, st := range r.Stores {
+				add(st.Quantity)
+			}
+			if !acc.hasQty {
+				add(r.Quantity)
+			}
+			acc.hasQty = acc.hasQty || stored
+		}
+	default:
+		for _, st := range o.Stores {
+			add(st.Quantity)
+		}
+	}
+}
+
+// applyOffers обновляет цену и остаток товаров, заведённых каталогом
+func (s *syncer) applyOffers() error {
+	for _, id := range s.order {
+		o := s.offers[id]
+		p, err := s.find(id)
+		if err != nil {
+			return err
+		}
+		if p == nil || p.DeletedAt.Valid {
+			s.res.Skipped++
+			continue
+		}
+		after := *p
+		if o.price == "" && p.PriceCents == 0 {
+			// черновик из каталога, цены ещё нет: меняется только остаток
+			if o.hasQty {
+				after.Stock = max(o.qty, 0)
+			}
+		} else {
+			// цена и остаток проходят те же правила, что форма и импорт: нулевая цена не попадёт на витрину
+			in := catalog.FromProduct(*p)
+			if o.price != "" {
+				in.Price, in.Currency = o.price, string(currency(o.currency, p.Currency))
+			}
+			if o.hasQty {
+				in.Stock = strconv.Itoa(max(o.qty, 0))
+			}
+			f, err := in.Validate()
+			if err != nil {
+				s.res.Skipped++
+				continue
+			}
+			f.ApplyTo(&after)
+		}
+		changed, err := s.save(*p, after)
+		if err != nil {
+			return err
+		}
+		if changed {
+			s.res.Updated++
+		} else {
+			s.res.Unchanged++
+		}
+	}
+	return nil
+}
+
+// quantity — количество из 1С: бывает дробным ("5.000"), берём целую часть
+func quantity(s string) (int, bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", "."))
+	if s == "" {
+		return 0, false
+	}
+	whole, _, _ := strings.Cut(s, ".")
+	var n int
+	if _, err := fmt.Sscanf(whole, "%d", &n); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// currency — валюта из 1С: "руб", "RUB", "643" и т.п.; незнакомая — def
+func currency(s string, def money.Currency) money.Currency {
+	switch strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(s, "."))) {
+	case "RUB", "RUR", "РУБ", "643":
+		return money.RUB
+	case "USD", "840", "$":
+		return money.USD
+	case "EUR", "978", "€":
+		return money.EUR
+	}
+	return def
+}
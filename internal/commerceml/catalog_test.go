@@ -0,0 +1,49 @@
+package commerceml
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+// Остаток считается по складам, а без них — по общему Количеству, но не дважды
+func TestCollectQuantity(t *testing.T) {
+	tests := []struct {
+		name, offer string
+		qty         int
+		hasQty      bool
+	}{
+		{"quantity", `<Количество>4</Количество>`, 4, true},
+		{"rest total", `<Остатки><Остаток><Количество>7</Количество></Остаток></Остатки>`, 7, true},
+		{"rest stores", `<Остатки><Остаток><Склад><Ид>1</Ид><Количество>2</Количество></Склад><Склад><Ид>2</Ид><Количество>3</Количество></Склад></Остаток></Остатки>`, 5, true},
+		{"rest stores and total", `<Остатки><Остаток><Склад><Ид>1</Ид><Количество>2</Количество></Склад><Склад><Ид>2</Ид><Количество>3</Количество></Склад><Количество>5</Количество></Остаток></Остатки>`, 5, true},
+		{"empty stores, total", `<Остатки><Остаток><Склад><Ид>1</Ид></Склад><Количество>6</Количество></Остаток></Остатки>`, 6, true},
+		{"old stores", `<Склад КоличествоНаСкладе="1"/><Склад КоличествоНаСкладе="2.000"/>`, 3, true},
+		{"none", ``, 0, false},
+	}
+	for _, tt := range tests {
+		var o xmlOffer
+		if err := xml.Unmarshal([]byte(`<Предложение><Ид>g1</Ид>`+tt.offer+`</Предложение>`), &o); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		s := &syncer{res: &Result{}, priceTypes: map[string]xmlPriceType{}, offers: map[string]*offer{}}
+		s.collect(o)
+		got := s.offers["g1"]
+		if got == nil || got.qty != tt.qty || got.hasQty != tt.hasQty {
+			t.Errorf("%s: offer %+v, want qty %d (%v)", tt.name, got, tt.qty, tt.hasQty)
+		}
+	}
+
+	// характеристики одного товара складываются
+	s := &syncer{res: &Result{}, priceTypes: map[string]xmlPriceType{}, offers: map[string]*offer{}}
+	for _, id := range []string{"g1#red", "g1#blue"} {
+		var o xmlOffer
+		body := `<Предложение><Ид>` + id + `</Ид><Остатки><Остаток><Склад><Количество>2</Количество></Склад><Количество>2</Количество></Остаток></Остатки></Предложение>`
+		if err := xml.Unmarshal([]byte(body), &o); err != nil {
+			t.Fatal(err)
+		}
+		s.collect(o)
+	}
+	if got := s.offers["g1"]; got.qty != 4 {
+		t.Errorf("characteristics: qty %d, want 4", got.qty)
+	}
+}
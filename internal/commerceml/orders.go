@@ -0,0 +1,177 @@
+package commerceml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// SchemaVersion — версия CommerceML в выгружаемых документах
+const SchemaVersion = "2.05"
+
+// ordersBatch — сколько заказов отдаём за один mode=query
+const ordersBatch = 100
+
+type xmlOrderLine struct {
+	ID      string `xml:"Ид"`
+	Article string `xml:"Артикул,omitempty"`
+	Name    string `xml:"Наименование"`
+	Price   string `xml:"ЦенаЗаЕдиницу"`
+	Qty     int    `xml:"Количество"`
+	Sum     string `xml:"Сумма"`
+}
+
+type xmlCounterparty struct {
+	ID   string `xml:"Ид"`
+	Name string `xml:"Наименование"`
+	Role string `xml:"Роль"`
+}
+
+type xmlDocument struct {
+	XMLName        xml.Name          `xml:"Документ"`
+	ID             string            `xml:"Ид"`
+	Number         string            `xml:"Номер"`
+	Date           string            `xml:"Дата"`
+	Time           string            `xml:"Время"`
+	Operation      string            `xml:"ХозОперация"`
+	Role           string            `xml:"Роль"`
+	Currency       string            `xml:"Валюта"`
+	Rate           string            `xml:"Курс"`
+	Sum            string            `xml:"Сумма"`
+	Counterparties []xmlCounterparty `xml:"Контрагенты>Контрагент"`
+	Lines          []xmlOrderLine    `xml:"Товары>Товар"`
+}
+
+// WriteOrders выгружает заказы с товарами продавца после заказа afterID — только его позиции.
+// Возвращает номер последнего выгруженного заказа (afterID, если новых нет).
+func WriteOrders(w io.Writer, db *gorm.DB, sellerID, afterID uint) (uint, error) {
+	var list []models.Order
+	err := db.Where("id > ? AND status IN ?", afterID, models.SaleStatuses).
+		Where("id IN (?)", db.Model(&models.OrderItem{}).Select("order_id").Where("seller_id = ?", sellerID)).
+		Preload("Items", "seller_id = ?", sellerID).
+		Order("id").Limit(ordersBatch).
+		Find(&list).Error
+	if err != nil {
+		return afterID, err
+	}
+
+	// Ид товаров в 1С и имена покупателей
+	var productIDs, buyerIDs []uint
+	for _, o := range list {
+		buyerIDs = append(buyerIDs, o.BuyerID)
+		for _, it := range o.Items {
+			productIDs = append(productIDs, it.ProductID)
+		}
+	}
+	var products []models.Product
+	if len(productIDs) > 0 {
+		if err := db.Unscoped().Select("id", "sku", "external_id").Find(&products, productIDs).Error; err != nil {
+			return afterID, err
+		}
+	}
+	byID := map[uint]models.Product{}
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	var buyers []models.User
+	if len(buyerIDs) > 0 {
+		if err := db.Select("id", "username").Find(&buyers, buyerIDs).Error; err != nil {
+			return afterID, err
+		}
+	}
+	names := map[uint]string{}
+	for _, u := range buyers {
+		names[u.ID] = u.Username
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return afterID, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	root := xml.StartElement{Name: xml.Name{Local: "КоммерческаяИнформация"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "ВерсияСхемы"}, Value: SchemaVersion},
+		{Name: xml.Name{Local: "ДатаФормирования"}, Value: time.Now().Format("2006-01-02T15:04:05")},
+	}}
+	if err := enc.EncodeToken(root); err != nil {
+		return afterID, err
+	}
+	last := afterID
+	for _, o := range list {
+		doc := xmlDocument{
+			ID:        strconv.FormatUint(uint64(o.ID), 10),
+			Number:    strconv.FormatUint(uint64(o.ID), 10),
+			Date:      o.CreatedAt.Format("2006-01-02"),
+			Time:      o.CreatedAt.Format("15:04:05"),
+			Operation: "Заказ товара",
+			Role:      "Продавец",
+			Currency:  string(o.Currency),
+			Rate:      "1",
+			Counterparties: []xmlCounterparty{{
+				ID:   strconv.FormatUint(uint64(o.BuyerID), 10),
+				Name: names[o.BuyerID],
+				Role: "Покупатель",
+			}},
+		}
+		var sum int64
+		for _, it := range o.Items {
+			p := byID[it.ProductID]
+			id := p.ExternalID
+			if id == "" {
+				id = strconv.FormatUint(uint64(it.ProductID), 10)
+			}
+			sub := it.Subtotal(o.Currency)
+			sum += sub.Amount
+			doc.Lines = append(doc.Lines, xmlOrderLine{
+				ID:      id,
+				Article: p.SKU,
+				Name:    it.Title,
+				Price:   money.New(it.UnitAmount, o.Currency).Decimal(),
+				Qty:     it.Qty,
+				Sum:     sub.Decimal(),
+			})
+		}
+		doc.Sum = money.New(sum, o.Currency).Decimal()
+		if err := enc.Encode(doc); err != nil {
+			return afterID, err
+		}
+		last = o.ID
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return afterID, err
+	}
+	if err := enc.Flush(); err != nil {
+		return afterID, err
+	}
+	return last, nil
+}
+
+// Ack — 1С подтвердила получение (mode=success): отданные заказы больше не выгружаем
+func Ack(db *gorm.DB, sellerID uint) error {
+	return db.Model(&models.ExchangeState{}).
+		Where("seller_id = ?", sellerID).
+		Update("orders_acked", gorm.Expr("orders_sent")).Error
+}
+
+// Pending — после какого заказа выгружать: всё, что 1С ещё не подтвердила
+func Pending(db *gorm.DB, sellerID uint) (uint, error) {
+	var st models.ExchangeState
+	err := db.Where(models.ExchangeState{SellerID: sellerID}).FirstOrInit(&st).Error
+	return st.OrdersAcked, err
+}
+
+// Sent запоминает последний отданный заказ до подтверждения
+func Sent(db *gorm.DB, sellerID, lastID uint) error {
+	st := models.ExchangeState{SellerID: sellerID, OrdersSent: lastID}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "seller_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"orders_sent", "updated_at"}),
+	}).Create(&st).Error
+}
@@ -0,0 +1,421 @@
+// Package disputes — возвраты и споры покупателя по позициям заказа: переписка,
+// ответ продавца, обратная отправка и решение с возвратом денег и товара на склад.
+package disputes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"marketplace/internal/ledger"
+	models "marketplace/internal/models"
+	"marketplace/internal/orders"
+)
+
+// ReturnWindow — сколько дней после получения можно открыть спор
+const ReturnWindow = 30 * 24 * time.Hour
+
+// MaxPhotos — фото к одному сообщению
+const MaxPhotos = 5
+
+var (
+	ErrWindow  = errors.New("the return window for this order has closed")
+	ErrOpen    = errors.New("there is already an open dispute for this item")
+	ErrState   = errors.New("the dispute is not in the right state for this")
+	ErrAmount  = errors.New("refund amount is outside the refundable range")
+	ErrInvalid = errors.New("invalid dispute")
+	// ErrNoSale — заказ оформлен до книги продаж: вернуть деньги через сайт нельзя
+	ErrNoSale = errors.New("this order was placed before online returns, please contact support")
+)
+
+// Input — заявка покупателя
+type Input struct {
+	Reason models.DisputeReason
+	Qty    int
+	Body   string
+	Photos []string // пути в uploads
+}
+
+// Open открывает спор по позиции заказа покупателя и замораживает выручку продавца, если её ещё не выплатили
+func Open(db *gorm.DB, buyerID uint, item models.OrderItem, in Input) (*models.Dispute, error) {
+	if in.Qty < 1 || in.Qty > item.Qty {
+		return nil, fmt.Errorf("%w: quantity must be 1..%d", ErrInvalid, item.Qty)
+	}
+	known := false
+	for _, r := range models.DisputeReasons {
+		known = known || r == in.Reason
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: choose a reason", ErrInvalid)
+	}
+	in.Body = strings.TrimSpace(in.Body)
+	if in.Body == "" {
+		return nil, fmt.Errorf("%w: describe the problem", ErrInvalid)
+	}
+	if len(in.Photos) > MaxPhotos {
+		return nil, fmt.Errorf("%w: up to %d photos", ErrInvalid, MaxPhotos)
+	}
+	d := &models.Dispute{
+		OrderID: item.OrderID, OrderItemID: item.ID, BuyerID: buyerID, SellerID: item.SellerID,
+		Reason: in.Reason, Qty: in.Qty, Status: models.DisputeOpen,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if sold, err := ledger.Sold(tx, item.OrderID, item.SellerID); err != nil {
+			return err
+		} else if !sold {
+			return ErrNoSale
+		}
+		var s models.Shipment
+		if err := tx.First(&s, "order_id = ? AND seller_id = ?", item.OrderID, item.SellerID).Error; err != nil {
+			return err
+		}
+		if s.DeliveredAt != nil && time.Since(*s.DeliveredAt) > ReturnWindow {
+			return ErrWindow
+		}
+		refunded, _, err := settled(tx, item.ID, 0)
+		if err != nil {
+			return err
+		}
+		if in.Qty > item.Qty-refunded {
+			return fmt.Errorf("%w: only %d of %d can still be returned", ErrInvalid, max(item.Qty-refunded, 0), item.Qty)
+		}
+		var n int64
+		if err := tx.Model(&models.Dispute{}).Where("order_item_id = ? AND status <> ?", item.ID, models.DisputeResolved).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrOpen
+		}
+		if err := tx.Create(d).Error; err != nil {
+			return err
+		}
+		if err := say(tx, d, buyerID, in.Body, in.Photos); err != nil {
+			return err
+		}
+		// выручка, которая уже вышла из удержания, не замораживается — спор закроется возвратом с баланса продавца
+		err = orders.Freeze(tx, item.OrderID, item.SellerID, fmt.Sprintf("dispute #%d", d.ID))
+		if err != nil && !errors.Is(err, orders.ErrShipmentState) {
+			return err
+		}
+		return notify(tx, d, d.SellerID, fmt.Sprintf("New dispute on order #%d", d.OrderID),
+			fmt.Sprintf("The buyer opened a dispute about %q: %s. Please respond.", item.Title, d.Reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// say — сообщение в переписку спора
+func say(tx *gorm.DB, d *models.Dispute, authorID uint, body string, photos []string) error {
+	m := models.DisputeMessage{DisputeID: d.ID, AuthorID: authorID, Body: body}
+	for _, p := range photos {
+		m.Photos = append(m.Photos, models.DisputePhoto{Path: p})
+	}
+	return tx.Create(&m).Error
+}
+
+// notify — уведомление участнику спора
+func notify(tx *gorm.DB, d *models.Dispute, userID uint, subject, body string) error {
+	return models.Notify(tx, models.Notification{
+		UserID:  userID,
+		Kind:    models.NotifyDispute,
+		Subject: subject,
+		Body:    body,
+		Link:    fmt.Sprintf("/disputes/%d", d.ID),
+	})
+}
+
+// lock — спор под блокировкой, в нужном статусе
+func lock(tx *gorm.DB, id uint, want ...models.DisputeStatus) (*models.Dispute, error) {
+	var d models.Dispute
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Item").First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range want {
+		if d.Status == s {
+			return &d, nil
+		}
+	}
+	return nil, ErrState
+}
+
+// Say — сообщение участника; второй стороне уходит уведомление
+func Say(db *gorm.DB, d *models.Dispute, authorID uint, body string, photos []string) error {
+	body = strings.TrimSpace(body)
+	if body == "" && len(photos) == 0 {
+		return fmt.Errorf("%w: empty message", ErrInvalid)
+	}
+	if len(photos) > MaxPhotos {
+		return fmt.Errorf("%w: up to %d photos", ErrInvalid, MaxPhotos)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := say(tx, d, authorID, body, photos); err != nil {
+			return err
+		}
+		subject := fmt.Sprintf("New message in dispute #%d", d.ID)
+		switch authorID {
+		case d.BuyerID:
+			return notify(tx, d, d.SellerID, subject, body)
+		case d.SellerID:
+			return notify(tx, d, d.BuyerID, subject, body)
+		}
+		// площадка пишет обеим сторонам
+		if err := notify(tx, d, d.BuyerID, subject, body); err != nil {
+			return err
+		}
+		return notify(tx, d, d.SellerID, subject, body)
+	})
+}
+
+// Accept — продавец согласен: с возвратом товара покупатель сначала отправляет его обратно,
+// без возврата деньги за позицию возвращаются сразу
+func Accept(db *gorm.DB, id, sellerID uint, withReturn bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeOpen)
+		if err != nil {
+			return err
+		}
+		if d.SellerID != sellerID {
+			return ErrState
+		}
+		if !withReturn {
+			return resolve(tx, d, sellerID, nil, models.OutcomeRefund, refundable(d), false, "The seller refunded the item without a return.")
+		}
+		if err := tx.Model(d).Update("status", models.DisputeReturnRequested).Error; err != nil {
+			return err
+		}
+		if err := say(tx, d, 0, "The seller accepted the return. Please ship the item back and enter the tracking number.", nil); err != nil {
+			return err
+		}
+		return notify(tx, d, d.BuyerID, fmt.Sprintf("Return accepted for order #%d", d.OrderID),
+			"The seller accepted your return. Ship the item back and enter the tracking number on the dispute page.")
+	})
+}
+
+// Reject — продавец не согласен; спор уходит на решение площадки
+func Reject(db *gorm.DB, id, sellerID uint, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("%w: explain why you reject the claim", ErrInvalid)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeOpen)
+		if err != nil {
+			return err
+		}
+		if d.SellerID != sellerID {
+			return ErrState
+		}
+		if err := tx.Model(d).Update("status", models.DisputeEscalated).Error; err != nil {
+			return err
+		}
+		if err := say(tx, d, sellerID, reason, nil); err != nil {
+			return err
+		}
+		if err := say(tx, d, 0, "The seller rejected the claim. The marketplace will review the dispute.", nil); err != nil {
+			return err
+		}
+		return notify(tx, d, d.BuyerID, fmt.Sprintf("Dispute #%d is under review", d.ID),
+			"The seller rejected your claim, our team will review it and decide.")
+	})
+}
+
+// ShipReturn — покупатель отправил товар обратно
+func ShipReturn(db *gorm.DB, id, buyerID uint, carrier, tracking string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeReturnRequested)
+		if err != nil {
+			return err
+		}
+		if d.BuyerID != buyerID {
+			return ErrState
+		}
+		err = tx.Model(d).Updates(map[string]any{
+			"status": models.DisputeReturning, "return_carrier": carrier, "return_tracking": tracking, "return_shipped_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := say(tx, d, 0, strings.TrimSpace("The buyer shipped the item back. "+carrier+" "+tracking), nil); err != nil {
+			return err
+		}
+		return notify(tx, d, d.SellerID, fmt.Sprintf("Return shipped for order #%d", d.OrderID),
+			"The buyer shipped the item back. Confirm when you receive it — the refund is issued then.")
+	})
+}
+
+// ReceiveReturn — продавец получил товар: деньги возвращаются, товар снова на складе
+func ReceiveReturn(db *gorm.DB, id, sellerID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeReturning)
+		if err != nil {
+			return err
+		}
+		if d.SellerID != sellerID {
+			return ErrState
+		}
+		if err := tx.Model(d).Update("return_received_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return resolve(tx, d, sellerID, nil, models.OutcomeRefund, refundable(d), true, "The seller received the item back. The buyer has been refunded.")
+	})
+}
+
+// Withdraw — покупатель отзывает спор
+func Withdraw(db *gorm.DB, id, buyerID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeOpen, models.DisputeReturnRequested, models.DisputeEscalated)
+		if err != nil {
+			return err
+		}
+		if d.BuyerID != buyerID {
+			return ErrState
+		}
+		return resolve(tx, d, buyerID, nil, models.OutcomeWithdrawn, 0, false, "The buyer withdrew the dispute.")
+	})
+}
+
+// Decision — решение площадки
+type Decision struct {
+	Refund  bool
+	Amount  int64 // в валюте заказа; 0 — вся стоимость спорных штук
+	Restock bool  // товар вернулся продавцу
+	Note    string
+}
+
+// Resolve — решение площадки по любому открытому спору
+func Resolve(db *gorm.DB, id, adminID uint, dec Decision) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		d, err := lock(tx, id, models.DisputeOpen, models.DisputeReturnRequested, models.DisputeReturning, models.DisputeEscalated)
+		if err != nil {
+			return err
+		}
+		note := strings.TrimSpace(dec.Note)
+		if !dec.Refund {
+			if note == "" {
+				note = "The marketplace rejected the claim."
+			}
+			return resolve(tx, d, adminID, &adminID, models.OutcomeRejected, 0, false, note)
+		}
+		amount := dec.Amount
+		if amount == 0 {
+			amount = refundable(d)
+		}
+		if note == "" {
+			note = "The marketplace decided to refund the buyer."
+		}
+		return resolve(tx, d, adminID, &adminID, models.OutcomeRefund, amount, dec.Restock, note)
+	})
+}
+
+// settled — сколько штук позиции уже вернули деньгами и сколько из них вернулось на склад
+// по закрытым спорам, кроме спора exceptID
+func settled(tx *gorm.DB, itemID, exceptID uint) (refunded, restocked int, err error) {
+	var row struct{ Refunded, Restocked int }
+	err = tx.Model(&models.Dispute{}).
+		Where("order_item_id = ? AND id <> ? AND status = ? AND outcome = ?", itemID, exceptID, models.DisputeResolved, models.OutcomeRefund).
+		Select("COALESCE(SUM(qty), 0) AS refunded, COALESCE(SUM(qty) FILTER (WHERE restocked), 0) AS restocked").
+		Scan(&row).Error
+	return row.Refunded, row.Restocked, err
+}
+
+// refundable — стоимость спорных штук позиции
+func refundable(d *models.Dispute) int64 {
+	return d.Item.UnitAmount * int64(d.Qty)
+}
+
+// resolve закрывает спор: возврат денег по книге, товар на склад, разморозка выручки,
+// когда по части заказа продавца больше нет открытых споров
+func resolve(tx *gorm.DB, d *models.Dispute, actorID uint, resolvedBy *uint, outcome models.DisputeOutcome, amount int64, restock bool, note string) error {
+	if outcome == models.OutcomeRefund {
+		if sold, err := ledger.Sold(tx, d.OrderID, d.SellerID); err != nil {
+			return err
+		} else if !sold {
+			return ErrNoSale
+		}
+		// по позиции нельзя вернуть больше, чем за неё заплачено, с учётом прошлых споров,
+		// а штук — больше, чем куплено (и вернулось на склад)
+		var refunded int64
+		err := tx.Model(&models.Dispute{}).
+			Where("order_item_id = ? AND id <> ?", d.OrderItemID, d.ID).
+			Select("COALESCE(SUM(refund_amount), 0)").Scan(&refunded).Error
+		if err != nil {
+			return err
+		}
+		if amount <= 0 || amount > d.Item.UnitAmount*int64(d.Item.Qty)-refunded {
+			return ErrAmount
+		}
+		refundedQty, restockedQty, err := settled(tx, d.OrderItemID, d.ID)
+		if err != nil {
+			return err
+		}
+		if d.Qty > d.Item.Qty-refundedQty {
+			return fmt.Errorf("%w: %d of %d items are already refunded", ErrInvalid, refundedQty, d.Item.Qty)
+		}
+		if restock && d.Qty > d.Item.Qty-restockedQty {
+			return fmt.Errorf("%w: %d of %d items are already back in stock", ErrInvalid, restockedQty, d.Item.Qty)
+		}
+		if err := ledger.Refund(tx, d.OrderID, d.SellerID, amount, fmt.Sprintf("dispute #%d", d.ID)); err != nil {
+			return err
+		}
+		if restock {
+			// товар мог уйти в архив — на склад он возвращается всё равно
+			m := models.InventoryMovement{
+				ProductID: d.Item.ProductID, WarehouseID: d.Item.WarehouseID, Delta: d.Qty,
+				Reason: models.MoveRefund, ActorID: actorID, OrderID: &d.OrderID, Note: fmt.Sprintf("dispute #%d", d.ID),
+			}
+			_, err := models.MoveStock(tx.Unscoped(), m)
+			if errors.Is(err, models.ErrWarehouse) {
+				// склад отгрузки успели удалить — кладём на склад по умолчанию
+				m.WarehouseID = nil
+				_, err = models.MoveStock(tx.Unscoped(), m)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	now := time.Now()
+	err := tx.Model(d).Updates(map[string]any{
+		"status": models.DisputeResolved, "outcome": outcome, "refund_amount": amount,
+		"restocked": restock, "resolved_at": now, "resolved_by": resolvedBy,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := say(tx, d, 0, note, nil); err != nil {
+		return err
+	}
+	var open int64
+	err = tx.Model(&models.Dispute{}).
+		Where("order_id = ? AND seller_id = ? AND status <> ?", d.OrderID, d.SellerID, models.DisputeResolved).
+		Count(&open).Error
+	if err != nil {
+		return err
+	}
+	if open == 0 {
+		if err := orders.Unfreeze(tx, d.OrderID, d.SellerID); err != nil {
+			return err
+		}
+	}
+	if outcome == models.OutcomeRefund {
+		if err := orders.CancelRefunded(tx, d.OrderID, d.SellerID); err != nil {
+			return err
+		}
+	}
+	subject := fmt.Sprintf("Dispute #%d closed: %s", d.ID, outcome)
+	for _, u := range []uint{d.BuyerID, d.SellerID} {
+		if u == actorID {
+			continue
+		}
+		if err := notify(tx, d, u, subject, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
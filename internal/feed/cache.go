@@ -0,0 +1,106 @@
+package feed
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// Dir — куда кэшируются готовые фиды
+const Dir = "feeds"
+
+// Cache хранит готовые фиды на диске и пересобирает их, только когда товары изменились.
+// Изменение видно по отпечатку: число товаров в продаже, последний updated_at и сумма версий
+// (версия растёт при каждой правке и продаже, число — при удалении и снятии с продажи).
+type Cache struct {
+	DB  *gorm.DB
+	Dir string
+
+	mu    sync.Mutex
+	stamp map[string]string // файл → отпечаток, с которым он собран
+}
+
+// NewCache — кэш фидов в dir
+func NewCache(db *gorm.DB, dir string) *Cache {
+	return &Cache{DB: db, Dir: dir, stamp: map[string]string{}}
+}
+
+// fingerprint — отпечаток товаров фида
+func (c *Cache) fingerprint(shop Shop) (string, error) {
+	var row struct {
+		N       int64
+		Updated *time.Time
+		Version int64
+	}
+	q := c.DB.Model(&models.Product{}).Scopes(models.ListedProducts, models.NotOnVacation).
+		Select("COUNT(*) AS n, MAX(products.updated_at) AS updated, COALESCE(SUM(products.version), 0) AS version")
+	if shop.SellerID != 0 {
+		q = q.Where("products.seller_id = ?", shop.SellerID)
+	}
+	if err := q.Scan(&row).Error; err != nil {
+		return "", err
+	}
+	updated := ""
+	if row.Updated != nil {
+		updated = row.Updated.UTC().Format(time.RFC3339Nano)
+	}
+	// адрес сайта и название тоже попадают в фид
+	return fmt.Sprintf("%d|%s|%d|%s|%s", row.N, updated, row.Version, shop.BaseURL, shop.Name), nil
+}
+
+// File — путь к актуальному фиду; при изменениях товаров фид пересобирается
+func (c *Cache) File(format Format, shop Shop) (string, error) {
+	if !format.Valid() {
+		return "", fmt.Errorf("unknown feed format %q", format)
+	}
+	name := filepath.Join(c.Dir, fmt.Sprintf("%s-%d.xml", format, shop.SellerID))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fp, err := c.fingerprint(shop)
+	if err != nil {
+		return "", err
+	}
+	if c.stamp[name] == fp {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+	if err := c.build(name, format, shop); err != nil {
+		return "", err
+	}
+	c.stamp[name] = fp
+	return name, nil
+}
+
+// build собирает фид во временный файл и подменяет старый: читатели не увидят половину файла
+func (c *Cache) build(name string, format Format, shop Shop) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, ".feed-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if err := Write(w, c.DB, format, shop); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,285 @@
+// Package feed — товарные фиды для внешних площадок: Яндекс Маркет (YML) и Google Merchant Center (RSS 2.0).
+// Фиды пишутся потоково, пачками из БД, и кэшируются на диске до изменения товаров.
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// Format — тип фида
+type Format string
+
+const (
+	YML    Format = "yml"
+	Google Format = "google"
+)
+
+// Valid — известный ли формат
+func (f Format) Valid() bool { return f == YML || f == Google }
+
+// Shop — чей фид: весь сайт (SellerID == 0) или один продавец
+type Shop struct {
+	Name     string
+	SellerID uint
+	BaseURL  string // абсолютный адрес сайта без "/" в конце
+}
+
+// batch — сколько товаров читаем из БД за раз
+const batch = 500
+
+// rootCategory — единственная категория в YML: категорий у товаров пока нет
+const rootCategory = 1
+
+// source отдаёт товары фида по одному
+type source func(fn func(models.Product) error) error
+
+// products отдаёт товары в продаже пачками
+func products(db *gorm.DB, sellerID uint, fn func(models.Product) error) error {
+	q := db.Scopes(models.ListedProducts, models.NotOnVacation).Order("products.id")
+	if sellerID != 0 {
+		q = q.Where("products.seller_id = ?", sellerID)
+	}
+	var list []models.Product
+	return q.FindInBatches(&list, batch, func(tx *gorm.DB, _ int) error {
+		for _, p := range list {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// Write пишет фид в w
+func Write(w io.Writer, db *gorm.DB, format Format, shop Shop) error {
+	return encode(w, format, shop, func(fn func(models.Product) error) error {
+		return products(db, shop.SellerID, fn)
+	})
+}
+
+// encode пишет фид по товарам из src
+func encode(w io.Writer, format Format, shop Shop, src source) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	var err error
+	switch format {
+	case YML:
+		err = writeYML(enc, src, shop)
+	case Google:
+		err = writeGoogle(enc, src, shop)
+	default:
+		err = fmt.Errorf("unknown feed format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func start(name string, attrs ...xml.Attr) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+}
+
+func attr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+// open/close — обёртки над EncodeToken, чтобы писать элементы по одному, не собирая фид в памяти
+func open(enc *xml.Encoder, name string, attrs ...xml.Attr) error {
+	return enc.EncodeToken(start(name, attrs...))
+}
+
+func closeTag(enc *xml.Encoder, name string) error {
+	return enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func productURL(shop Shop, p models.Product) string {
+	return fmt.Sprintf("%s/product/%d", shop.BaseURL, p.ID)
+}
+
+func imageURL(shop Shop, p models.Product) string {
+	if p.ImagePath == "" {
+		return ""
+	}
+	return shop.BaseURL + p.ImagePath
+}
+
+// ---- YML ----
+
+// ymlCurrency — код валюты в YML: рубль там RUR
+func ymlCurrency(c money.Currency) string {
+	if c == money.RUB {
+		return "RUR"
+	}
+	return string(c)
+}
+
+type ymlOffer struct {
+	XMLName     xml.Name `xml:"offer"`
+	ID          uint     `xml:"id,attr"`
+	Available   bool     `xml:"available,attr"`
+	URL         string   `xml:"url"`
+	Price       string   `xml:"price"`
+	CurrencyID  string   `xml:"currencyId"`
+	CategoryID  int      `xml:"categoryId"`
+	Picture     string   `xml:"picture,omitempty"`
+	Name        string   `xml:"name"`
+	Description string   `xml:"description,omitempty"`
+	VendorCode  string   `xml:"vendorCode,omitempty"`
+	Count       int      `xml:"count"`
+}
+
+func writeYML(enc *xml.Encoder, src source, shop Shop) error {
+	if err := open(enc, "yml_catalog", attr("date", time.Now().Format("2006-01-02T15:04-07:00"))); err != nil {
+		return err
+	}
+	if err := open(enc, "shop"); err != nil {
+		return err
+	}
+	head := []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	}{
+		{xml.Name{Local: "name"}, shop.Name},
+		{xml.Name{Local: "company"}, shop.Name},
+		{xml.Name{Local: "url"}, shop.BaseURL + "/"},
+	}
+	for _, h := range head {
+		if err := enc.Encode(h); err != nil {
+			return err
+		}
+	}
+
+	// курсы: рубль основной, остальные — по ЦБ на стороне Маркета
+	if err := open(enc, "currencies"); err != nil {
+		return err
+	}
+	for _, c := range money.Supported {
+		rate := "CBRF"
+		if c == money.RUB {
+			rate = "1"
+		}
+		if err := enc.EncodeElement("", start("currency", attr("id", ymlCurrency(c)), attr("rate", rate))); err != nil {
+			return err
+		}
+	}
+	if err := closeTag(enc, "currencies"); err != nil {
+		return err
+	}
+	if err := open(enc, "categories"); err != nil {
+		return err
+	}
+	if err := enc.EncodeElement("Products", start("category", attr("id", fmt.Sprint(rootCategory)))); err != nil {
+		return err
+	}
+	if err := closeTag(enc, "categories"); err != nil {
+		return err
+	}
+
+	if err := open(enc, "offers"); err != nil {
+		return err
+	}
+	err := src(func(p models.Product) error {
+		return enc.Encode(ymlOffer{
+			ID:          p.ID,
+			Available:   p.Stock > 0,
+			URL:         productURL(shop, p),
+			Price:       p.Price().Decimal(),
+			CurrencyID:  ymlCurrency(p.Currency),
+			CategoryID:  rootCategory,
+			Picture:     imageURL(shop, p),
+			Name:        p.Title,
+			Description: p.Description,
+			VendorCode:  p.SKU,
+			Count:       p.Stock,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"offers", "shop", "yml_catalog"} {
+		if err := closeTag(enc, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---- Google Merchant Center ----
+
+// googleNS — пространство имён атрибутов g:*
+const googleNS = "http://base.google.com/ns/1.0"
+
+// googleItem — поля с префиксом g: пишутся как есть (encoding/xml не разбирает префиксы в тегах)
+type googleItem struct {
+	XMLName          xml.Name `xml:"item"`
+	ID               uint     `xml:"g:id"`
+	Title            string   `xml:"title"`
+	Description      string   `xml:"description"`
+	Link             string   `xml:"link"`
+	ImageLink        string   `xml:"g:image_link"`
+	Availability     string   `xml:"g:availability"`
+	Price            string   `xml:"g:price"`
+	Condition        string   `xml:"g:condition"`
+	MPN              string   `xml:"g:mpn,omitempty"`
+	IdentifierExists string   `xml:"g:identifier_exists"`
+}
+
+func writeGoogle(enc *xml.Encoder, src source, shop Shop) error {
+	if err := open(enc, "rss", attr("version", "2.0"), attr("xmlns:g", googleNS)); err != nil {
+		return err
+	}
+	if err := open(enc, "channel"); err != nil {
+		return err
+	}
+	for _, h := range [][2]string{{"title", shop.Name}, {"link", shop.BaseURL + "/"}, {"description", shop.Name + " products"}} {
+		if err := enc.EncodeElement(h[1], start(h[0])); err != nil {
+			return err
+		}
+	}
+	err := src(func(p models.Product) error {
+		// без картинки Merchant Center товар всё равно отклонит
+		if p.ImagePath == "" {
+			return nil
+		}
+		availability := "in_stock"
+		if p.Stock <= 0 {
+			availability = "out_of_stock"
+		}
+		description := p.Description
+		if strings.TrimSpace(description) == "" {
+			description = p.Title
+		}
+		return enc.Encode(googleItem{
+			ID:               p.ID,
+			Title:            p.Title,
+			Description:      description,
+			Link:             productURL(shop, p),
+			ImageLink:        imageURL(shop, p),
+			Availability:     availability,
+			Price:            p.Price().Decimal() + " " + string(p.Currency),
+			Condition:        "new",
+			MPN:              p.SKU,
+			IdentifierExists: "no",
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := closeTag(enc, "channel"); err != nil {
+		return err
+	}
+	return closeTag(enc, "rss")
+}
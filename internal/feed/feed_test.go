@@ -0,0 +1,237 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// node — любой элемент XML с атрибутами и детьми
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []node     `xml:",any"`
+}
+
+func (n node) attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n node) child(name xml.Name) (node, bool) {
+	for _, c := range n.Children {
+		if c.XMLName == name {
+			return c, true
+		}
+	}
+	return node{}, false
+}
+
+// find — элементы по пути от n: "shop/offers/offer"
+func (n node) find(path string) []node {
+	list := []node{n}
+	for _, name := range strings.Split(path, "/") {
+		var next []node
+		for _, p := range list {
+			for _, c := range p.Children {
+				if c.XMLName.Local == name {
+					next = append(next, c)
+				}
+			}
+		}
+		list = next
+	}
+	return list
+}
+
+// schema — обязательная часть формата фида по документации площадки
+type schema struct {
+	Root      string
+	RootAttrs map[string]string // атрибут → значение ("" — любое непустое)
+	Head      []string          // пути от корня, которые должны быть
+	Item      string            // путь к товару
+	ItemAttrs []string
+	Fields    []xml.Name                  // обязательные непустые поля товара
+	Values    map[xml.Name]*regexp.Regexp // формат значений полей
+	NS        string                      // пространство имён полей с префиксом; "" — без префиксов
+	ID        func(node) string
+}
+
+var yml = schema{
+	Root:      "yml_catalog",
+	RootAttrs: map[string]string{"date": ""},
+	Head:      []string{"shop/name", "shop/company", "shop/url", "shop/currencies/currency", "shop/categories/category", "shop/offers"},
+	Item:      "shop/offers/offer",
+	ItemAttrs: []string{"id", "available"},
+	Fields: []xml.Name{
+		{Local: "url"}, {Local: "price"}, {Local: "currencyId"}, {Local: "categoryId"}, {Local: "name"},
+	},
+	Values: map[xml.Name]*regexp.Regexp{
+		{Local: "price"}:      regexp.MustCompile(`^\d+(\.\d{1,2})?$`),
+		{Local: "currencyId"}: regexp.MustCompile(`^(RUR|USD|EUR)$`),
+		{Local: "count"}:      regexp.MustCompile(`^\d+$`),
+	},
+	ID: func(n node) string { id, _ := n.attr("id"); return id },
+}
+
+var google = schema{
+	Root:      "rss",
+	RootAttrs: map[string]string{"version": "2.0"},
+	Head:      []string{"channel/title", "channel/link", "channel/description"},
+	Item:      "channel/item",
+	Fields: []xml.Name{
+		{Space: googleNS, Local: "id"}, {Local: "title"}, {Local: "description"}, {Local: "link"},
+		{Space: googleNS, Local: "image_link"}, {Space: googleNS, Local: "availability"},
+		{Space: googleNS, Local: "price"}, {Space: googleNS, Local: "condition"},
+	},
+	Values: map[xml.Name]*regexp.Regexp{
+		{Space: googleNS, Local: "availability"}: regexp.MustCompile(`^(in_stock|out_of_stock|preorder|backorder)$`),
+		{Space: googleNS, Local: "price"}:        regexp.MustCompile(`^\d+(\.\d{2})? [A-Z]{3}$`),
+		{Space: googleNS, Local: "condition"}:    regexp.MustCompile(`^(new|refurbished|used)$`),
+		{Space: googleNS, Local: "image_link"}:   regexp.MustCompile(`^https?://`),
+	},
+	NS: googleNS,
+	ID: func(n node) string {
+		c, _ := n.child(xml.Name{Space: googleNS, Local: "id"})
+		return c.Text
+	},
+}
+
+// validate проверяет фид по схеме и возвращает число товаров
+func validate(t *testing.T, name string, doc []byte, s schema) int {
+	t.Helper()
+	var root node
+	if err := xml.Unmarshal(doc, &root); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if root.XMLName.Local != s.Root {
+		t.Fatalf("%s: root <%s>, want <%s>", name, root.XMLName.Local, s.Root)
+	}
+	for a, want := range s.RootAttrs {
+		v, ok := root.attr(a)
+		if !ok || v == "" || (want != "" && v != want) {
+			t.Errorf("%s: <%s %s=%q>, want %q", name, s.Root, a, v, want)
+		}
+	}
+	for _, path := range s.Head {
+		if len(root.find(path)) == 0 {
+			t.Errorf("%s: no %s", name, path)
+		}
+	}
+	ids := map[string]bool{}
+	items := root.find(s.Item)
+	for i, item := range items {
+		for _, a := range s.ItemAttrs {
+			if v, ok := item.attr(a); !ok || v == "" {
+				t.Errorf("%s: item %d: no %s attribute", name, i, a)
+			}
+		}
+		for _, f := range s.Fields {
+			if c, ok := item.child(f); !ok || strings.TrimSpace(c.Text) == "" {
+				t.Errorf("%s: item %d: no %s:%s", name, i, f.Space, f.Local)
+			}
+		}
+		for _, c := range item.Children {
+			if re := s.Values[c.XMLName]; re != nil && !re.MatchString(c.Text) {
+				t.Errorf("%s: item %d: %s = %q", name, i, c.XMLName.Local, c.Text)
+			}
+			// префикс g: должен раскрываться в пространство имён Google, а не остаться в имени
+			if strings.Contains(c.XMLName.Local, ":") || (c.XMLName.Space != "" && c.XMLName.Space != s.NS) {
+				t.Errorf("%s: item %d: element %s:%s outside the feed namespace", name, i, c.XMLName.Space, c.XMLName.Local)
+			}
+		}
+		id := s.ID(item)
+		if id == "" {
+			t.Errorf("%s: item %d: empty id", name, i)
+		}
+		if ids[id] {
+			t.Errorf("%s: duplicate id %s", name, id)
+		}
+		ids[id] = true
+	}
+	return len(items)
+}
+
+var fixtures = []models.Product{
+	{Base: models.Base{ID: 1}, SellerID: 7, SKU: "KT-1", Title: `Kettle <Pro> & "Co"`, Description: "Steel, 1.7 l",
+		PriceCents: 129950, Currency: money.RUB, Stock: 3, ImagePath: "/uploads/kettle.jpg"},
+	{Base: models.Base{ID: 2}, SellerID: 7, Title: "Mug", PriceCents: 500, Currency: money.USD, ImagePath: "/uploads/mug.jpg"},
+	{Base: models.Base{ID: 3}, SellerID: 8, Title: "No photo", PriceCents: 100, Currency: money.EUR, Stock: 1},
+}
+
+func fixtureSource(fn func(models.Product) error) error {
+	for _, p := range fixtures {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Схемы сначала проверяются на образцах из документации, потом на наших фидах
+func TestSamplesMatchSchema(t *testing.T) {
+	for file, s := range map[string]schema{"testdata/yml_sample.xml": yml, "testdata/google_sample.xml": google} {
+		doc, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := validate(t, file, doc, s); n == 0 {
+			t.Errorf("%s: no items", file)
+		}
+	}
+}
+
+func TestFeedsMatchSchema(t *testing.T) {
+	shop := Shop{Name: "Market & Co", BaseURL: "https://shop.example"}
+	tests := []struct {
+		format Format
+		schema schema
+		items  int
+	}{
+		{YML, yml, 3},
+		{Google, google, 2}, // без картинки товар в Google не попадает
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := encode(&b, tt.format, shop, fixtureSource); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if n := validate(t, string(tt.format), b.Bytes(), tt.schema); n != tt.items {
+			t.Errorf("%s: %d items, want %d\n%s", tt.format, n, tt.items, b.String())
+		}
+	}
+}
+
+func TestGoogleNamespace(t *testing.T) {
+	var b bytes.Buffer
+	if err := encode(&b, Google, Shop{Name: "S", BaseURL: "https://shop.example"}, fixtureSource); err != nil {
+		t.Fatal(err)
+	}
+	var root node
+	if err := xml.Unmarshal(b.Bytes(), &root); err != nil {
+		t.Fatal(err)
+	}
+	if ns, _ := root.attr("g"); ns != googleNS {
+		t.Errorf("xmlns:g = %q, want %q", ns, googleNS)
+	}
+	item := root.find("channel/item")[0]
+	price, ok := item.child(xml.Name{Space: googleNS, Local: "price"})
+	if !ok || price.Text != "1299.50 RUB" {
+		t.Errorf("g:price = %q, want %q", price.Text, "1299.50 RUB")
+	}
+	title, _ := item.child(xml.Name{Local: "title"})
+	if title.Text != fixtures[0].Title {
+		t.Errorf("title = %q, want %q", title.Text, fixtures[0].Title)
+	}
+}
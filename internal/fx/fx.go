@@ -0,0 +1,136 @@
+// Package fx — курсы валют из таблицы exchange_rates и пересчёт цен для показа и оплаты.
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// ErrNoRate — нет ни прямого, ни обратного, ни кросс-курса
+var ErrNoRate = errors.New("no exchange rate")
+
+type pair struct{ from, to money.Currency }
+
+// Table — снимок таблицы курсов в памяти
+type Table struct {
+	rates map[pair]float64
+}
+
+// Load читает все курсы
+func Load(db *gorm.DB) (Table, error) {
+	var rows []models.ExchangeRate
+	if err := db.Find(&rows).Error; err != nil {
+		return Table{}, err
+	}
+	t := Table{rates: make(map[pair]float64, len(rows))}
+	for _, r := range rows {
+		if r.Rate > 0 {
+			t.rates[pair{r.Base, r.Quote}] = r.Rate
+		}
+	}
+	return t, nil
+}
+
+func (t Table) direct(from, to money.Currency) (float64, bool) {
+	if r, ok := t.rates[pair{from, to}]; ok {
+		return r, true
+	}
+	if r, ok := t.rates[pair{to, from}]; ok {
+		return 1 / r, true
+	}
+	return 0, false
+}
+
+// Rate — сколько to за 1 from: прямой курс, обратный или кросс через третью валюту
+func (t Table) Rate(from, to money.Currency) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if r, ok := t.direct(from, to); ok {
+		return r, nil
+	}
+	for _, via := range money.Supported {
+		a, ok1 := t.direct(from, via)
+		b, ok2 := t.direct(via, to)
+		if ok1 && ok2 {
+			return a * b, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %s→%s", ErrNoRate, from, to)
+}
+
+// Convert переводит m в валюту to
+func (t Table) Convert(m money.Money, to money.Currency) (money.Money, error) {
+	r, err := t.Rate(m.Currency, to)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return m.Convert(to, r), nil
+}
+
+// Cache держит Table в памяти и перечитывает её не чаще раза в ttl
+type Cache struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu     sync.Mutex
+	table  Table
+	loaded time.Time
+}
+
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	return &Cache{db: db, ttl: ttl}
+}
+
+// Table — текущие курсы; при ошибке БД отдаёт последний удачный снимок
+func (c *Cache) Table() Table {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.loaded) < c.ttl {
+		return c.table
+	}
+	t, err := Load(c.db)
+	if err != nil {
+		return c.table
+	}
+	c.table, c.loaded = t, time.Now()
+	return t
+}
+
+// Converter — курсы + валюта, в которой покупатель смотрит цены
+type Converter struct {
+	Table Table
+	To    money.Currency
+}
+
+// Convert — сумма в валюте показа; если курса нет, остаётся в исходной валюте
+func (c Converter) Convert(m money.Money) money.Money {
+	if out, err := c.Table.Convert(m, c.To); err == nil {
+		return out
+	}
+	return m
+}
+
+// Show — отформатированная сумма в валюте показа (для шаблонов)
+func (c Converter) Show(m money.Money) string {
+	return c.Convert(m).String()
+}
+
+// Set записывает курс base→quote (upsert по паре)
+func Set(db *gorm.DB, base, quote money.Currency, rate float64, source string) error {
+	if base == quote || rate <= 0 {
+		return fmt.Errorf("bad rate %s→%s = %v", base, quote, rate)
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(&models.ExchangeRate{Base: base, Quote: quote, Rate: rate, Source: source}).Error
+}
@@ -0,0 +1,63 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/money"
+)
+
+// Quotes — курсы от провайдера: сколько каждой валюты за 1 Base
+type Quotes struct {
+	Base  money.Currency             `json:"base"`
+	Rates map[money.Currency]float64 `json:"rates"`
+}
+
+// Provider — источник курсов (ЦБ, банк, файл). Новые провайдеры подключаются через Sync.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context) (Quotes, error)
+}
+
+// FileProvider читает курсы из JSON-файла вида {"base":"USD","rates":{"RUB":92.5,"EUR":0.92}}.
+// Для разработки и тестов, а также для ручной выгрузки курсов.
+type FileProvider struct {
+	Path string
+}
+
+func (p FileProvider) Name() string { return "file" }
+
+func (p FileProvider) Fetch(ctx context.Context) (Quotes, error) {
+	var q Quotes
+	b, err := os.ReadFile(p.Path)
+	if err != nil {
+		return q, err
+	}
+	err = json.Unmarshal(b, &q)
+	return q, err
+}
+
+// Sync забирает курсы у провайдера и сохраняет их в exchange_rates. Возвращает число записанных пар.
+func Sync(ctx context.Context, db *gorm.DB, p Provider) (int, error) {
+	q, err := p.Fetch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for cur, rate := range q.Rates {
+			if cur == q.Base {
+				continue
+			}
+			if err := Set(tx, q.Base, cur, rate, p.Name()); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
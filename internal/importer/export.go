@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+)
+
+// ImageColumn — ссылка на картинку в выгрузке. При импорте не сопоставляется и пропускается.
+const ImageColumn = "image_url"
+
+// ArchivedColumn — "yes" у архивного товара. Импорт его тоже пропускает:
+// в архив и обратно товар переносят во вкладке «Архив», а поля архивного товара импорт обновляет как обычно.
+const ArchivedColumn = "archived"
+
+// exportBatch — сколько товаров читаем из БД за раз
+const exportBatch = 500
+
+// Columns — колонки выгрузки: поля импорта в том же порядке, чтобы файл загружался обратно как есть.
+// Категории нет: импорт её не принимает, а выгрузка — это файл для обратной загрузки.
+func Columns() []string {
+	cols := make([]string, 0, len(Fields)+2)
+	for _, f := range Fields {
+		cols = append(cols, f.Name)
+	}
+	return append(cols, ImageColumn, ArchivedColumn)
+}
+
+// record — строка выгрузки в формате, который принимает catalog.Input.Validate
+func record(p models.Product, baseURL string) []string {
+	in := catalog.FromProduct(p)
+	img := ""
+	if p.ImagePath != "" {
+		img = strings.TrimRight(baseURL, "/") + p.ImagePath
+	}
+	archived := ""
+	if p.ArchivedAt != nil {
+		archived = "yes"
+	}
+	return []string{strconv.FormatUint(uint64(p.ID), 10), in.SKU, in.Title, in.Description, in.Price, in.Currency, in.Stock, in.Status, in.PublishAt, in.LowStock, img, archived}
+}
+
+// source перебирает товары выгрузки
+type source func(fn func(models.Product) error) error
+
+// sellerProducts отдаёт товары продавца вместе с архивными пачками, не держа всё в памяти.
+// Удалённых нет: импорт их не обновляет.
+func sellerProducts(db *gorm.DB, sellerID uint) source {
+	return func(fn func(models.Product) error) error {
+		var batch []models.Product
+		return db.Where("seller_id = ?", sellerID).
+			Order("id").
+			FindInBatches(&batch, exportBatch, func(tx *gorm.DB, _ int) error {
+				for _, p := range batch {
+					if err := fn(p); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	}
+}
+
+// ExportCSV пишет товары продавца в CSV (UTF-8 с BOM, чтобы Excel открыл кириллицу)
+func ExportCSV(w io.Writer, db *gorm.DB, sellerID uint, baseURL string) error {
+	return writeCSV(w, sellerProducts(db, sellerID), baseURL)
+}
+
+func writeCSV(w io.Writer, products source, baseURL string) error {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns()); err != nil {
+		return err
+	}
+	err := products(func(p models.Product) error {
+		return cw.Write(record(p, baseURL))
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// ExportJSON пишет товары продавца массивом объектов с ключами из Columns
+func ExportJSON(w io.Writer, db *gorm.DB, sellerID uint, baseURL string) error {
+	return writeJSON(w, sellerProducts(db, sellerID), baseURL)
+}
+
+func writeJSON(w io.Writer, products source, baseURL string) error {
+	cols := Columns()
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := products(func(p models.Product) error {
+		obj := make(map[string]string, len(cols))
+		for i, v := range record(p, baseURL) {
+			obj[cols[i]] = v
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ",\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// Выгрузка читается импортом обратно как есть: все поля сопоставляются по заголовку,
+// товар находится по id (у товара без артикула другого ключа нет), значения проходят проверку формы.
+func TestExportRoundTrip(t *testing.T) {
+	archived := time.Now()
+	products := []models.Product{
+		{Base: models.Base{ID: 7}, Title: "No SKU", Description: "a, \"quoted\"\nline", PriceCents: 129950,
+			Currency: money.RUB, Stock: 3, Status: models.StatusPublished, ImagePath: "/uploads/a.jpg"},
+		{Base: models.Base{ID: 8}, SKU: "KT-1", Title: "Kettle", PriceCents: 500, Currency: money.USD,
+			Status: models.StatusDraft, LowStock: 2},
+		{Base: models.Base{ID: 9}, SKU: "OLD-1", Title: "Old kettle", PriceCents: 100, Currency: money.EUR,
+			Status: models.StatusUnpublished, ArchivedAt: &archived},
+	}
+	each := func(fn func(models.Product) error) error {
+		for _, p := range products {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dir := t.TempDir()
+
+	for name, export := range map[string]func(io.Writer, source, string) error{
+		"export.csv":  writeCSV,
+		"export.json": writeJSON,
+	} {
+		var buf bytes.Buffer
+		if err := export(&buf, each, "https://shop.example"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(rows) != len(products)+1 {
+			t.Fatalf("%s: %d rows, want %d", name, len(rows), len(products)+1)
+		}
+		mapping := GuessMapping(rows[0])
+		archivedCol := -1
+		for i, h := range rows[0] {
+			if h == ArchivedColumn {
+				archivedCol = i
+			}
+		}
+		if _, ok := mapping[ArchivedColumn]; ok || archivedCol < 0 {
+			t.Errorf("%s: archived column mapped %v, present %v", name, ok, archivedCol >= 0)
+		}
+		for _, field := range Fields {
+			if _, ok := mapping[field.Name]; !ok {
+				t.Errorf("%s: column %s is not mapped", name, field.Name)
+			}
+		}
+		for i, p := range products {
+			row := rows[i+1]
+			if got, want := row[archivedCol], p.ArchivedAt != nil; (got == "yes") != want {
+				t.Errorf("%s: row %d: archived = %q, want %v", name, i, got, want)
+			}
+			if id, _ := cell(row, mapping, "id"); id != strconv.FormatUint(uint64(p.ID), 10) {
+				t.Errorf("%s: row %d: id = %q, want %d", name, i, id, p.ID)
+			}
+			if sku, _ := cell(row, mapping, "sku"); sku != p.SKU {
+				t.Errorf("%s: row %d: sku = %q, want %q", name, i, sku, p.SKU)
+			}
+			// строку накладываем на пустой товар: в файле должно быть всё
+			got, err := overlay(catalog.Input{SKU: p.SKU}, row, mapping).Validate()
+			if err != nil {
+				t.Fatalf("%s: row %d: %v", name, i, err)
+			}
+			var back models.Product
+			got.ApplyTo(&back)
+			back.ID, back.ImagePath = p.ID, p.ImagePath
+			if diff := models.SnapshotOf(p).Diff(models.SnapshotOf(back)); len(diff) > 0 {
+				t.Errorf("%s: row %d changed on the way back: %v", name, i, diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package importer — массовая загрузка товаров продавца из CSV/XLSX.
+// Строки сопоставляются с товарами по id (есть в выгрузке) или по артикулу продавца (SKU)
+// и проверяются теми же правилами, что и форма (catalog.Input.Validate).
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/catalog"
+	models "marketplace/internal/models"
+)
+
+// Dir — куда сохраняются загруженные файлы импорта (наружу не раздаётся)
+const Dir = "imports"
+
+// MaxFileSize — предел размера файла импорта
+const MaxFileSize = 10 << 20
+
+// Field — поле товара, которое можно загрузить из файла
+type Field struct {
+	Name     string
+	Required bool
+	aliases  []string // как колонка обычно называется в файлах продавцов
+}
+
+// Fields — поля в порядке показа на шаге сопоставления колонок
+var Fields = []Field{
+	{Name: "id", aliases: []string{"product id", "id товара"}}, // из выгрузки; у товара без артикула это единственный ключ
+	{Name: "sku", aliases: []string{"артикул", "article", "vendor code"}},
+	{Name: "title", aliases: []string{"name", "название", "наименование"}},
+	{Name: "description", aliases: []string{"описание"}},
+	{Name: "price", aliases: []string{"цена", "cost"}},
+	{Name: "currency", aliases: []string{"валюта"}},
+	{Name: "stock", aliases: []string{"остаток", "количество", "qty", "quantity"}},
+	{Name: "status", aliases: []string{"статус"}},
+	{Name: "publish_at", aliases: []string{"дата публикации"}},
+	{Name: "low_stock", aliases: []string{"порог остатка"}},
+}
+
+// GuessMapping сопоставляет колонки по названиям в заголовке
+func GuessMapping(header []string) map[string]int {
+	m := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for _, f := range Fields {
+			if _, done := m[f.Name]; done {
+				continue
+			}
+			if h == f.Name {
+				m[f.Name] = i
+				continue
+			}
+			for _, a := range f.aliases {
+				if h == a {
+					m[f.Name] = i
+				}
+			}
+		}
+	}
+	return m
+}
+
+// cell — значение поля в строке или "" если колонка не сопоставлена
+func cell(row []string, mapping map[string]int, field string) (string, bool) {
+	i, ok := mapping[field]
+	if !ok || i < 0 || i >= len(row) {
+		return "", false
+	}
+	return strings.TrimSpace(row[i]), true
+}
+
+// overlay накладывает непустые ячейки строки на in. Пустая ячейка — «не менять».
+func overlay(in catalog.Input, row []string, mapping map[string]int) catalog.Input {
+	set := func(dst *string, field string) {
+		if v, ok := cell(row, mapping, field); ok && v != "" {
+			*dst = v
+		}
+	}
+	set(&in.Title, "title")
+	set(&in.Description, "description")
+	set(&in.Price, "price")
+	set(&in.Currency, "currency")
+	set(&in.Stock, "stock")
+	set(&in.Status, "status")
+	set(&in.PublishAt, "publish_at")
+	set(&in.LowStock, "low_stock")
+	return in
+}
+
+// report копит итоги по строкам
+type report struct{ models.ImportReport }
+
+func (r *report) add(row models.ImportRow) {
+	switch row.Action {
+	case "create":
+		r.Creates++
+	case "update":
+		r.Updates++
+	case "unchanged":
+		r.Unchanged++
+		return // в отчёт не пишем, их может быть тысячи
+	case "error":
+		r.Errors++
+	}
+	if len(r.Rows) < models.ImportReportRows {
+		r.Rows = append(r.Rows, row)
+	}
+}
+
+// process прогоняет строки файла. dryRun — только посчитать, что будет; иначе записать в tx.
+func process(tx *gorm.DB, job *models.ImportJob, rows [][]string, dryRun bool) (models.ImportReport, error) {
+	var rep report
+	_, hasSKU := job.Mapping["sku"]
+	_, hasID := job.Mapping["id"]
+	if !hasSKU && !hasID {
+		return rep.ImportReport, fmt.Errorf("map the sku or id column")
+	}
+
+	// все товары продавца, включая удалённые (артикул у них тоже занят)
+	var existing []models.Product
+	if err := tx.Unscoped().Where("seller_id = ?", job.SellerID).Find(&existing).Error; err != nil {
+		return rep.ImportReport, err
+	}
+	byID := make(map[uint]*models.Product, len(existing))
+	bySKU := make(map[string]*models.Product, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+		if existing[i].SKU != "" {
+			bySKU[existing[i].SKU] = &existing[i]
+		}
+	}
+	// непроверенный продавец загружает черновики (catalog.CheckPublish)
+	verified, err := models.SellerVerified(tx, job.SellerID)
+	if err != nil {
+		return rep.ImportReport, err
+	}
+
+	seen := map[string]int{} // артикул → строка
+	seenID := map[uint]int{} // товар → строка
+	for i, row := range rows {
+		res := models.ImportRow{Row: i + 2} // +1 заголовок, +1 с единицы
+		res.SKU, _ = cell(row, job.Mapping, "sku")
+		idCell, _ := cell(row, job.Mapping, "id")
+		fail := func(format string, args ...any) {
+			res.Action, res.Error = "error", fmt.Sprintf(format, args...)
+			rep.add(res)
+		}
+
+		// товар ищем по id, а без него — по артикулу; новый товар без артикула не создаём:
+		// повторная загрузка того же файла создала бы его ещё раз
+		var p *models.Product
+		found := false
+		if idCell != "" {
+			id, err := strconv.ParseUint(idCell, 10, 64)
+			if err != nil {
+				fail("invalid product id %q", idCell)
+				continue
+			}
+			if p, found = byID[uint(id)]; !found {
+				fail("product #%d not found", id)
+				continue
+			}
+			if res.SKU == "" {
+				res.SKU = p.SKU // пустая ячейка — артикул не меняется
+			} else if other, taken := bySKU[res.SKU]; taken && other.ID != p.ID {
+				fail("SKU %q is already used by product #%d", res.SKU, other.ID)
+				continue
+			}
+		} else {
+			if res.SKU == "" {
+				fail("SKU or product id is required")
+				continue
+			}
+			p, found = bySKU[res.SKU]
+		}
+		if res.SKU != "" {
+			if prev, dup := seen[res.SKU]; dup {
+				fail("duplicate SKU, already in row %d", prev)
+				continue
+			}
+			seen[res.SKU] = res.Row
+		}
+		if found {
+			if prev, dup := seenID[p.ID]; dup {
+				fail("product #%d is already in row %d", p.ID, prev)
+				continue
+			}
+			seenID[p.ID] = res.Row
+		}
+
+		in := catalog.Input{SKU: res.SKU, Currency: string(job.Currency)}
+		if !verified {
+			in.Status = string(models.StatusDraft)
+		}
+		if found {
+			if p.DeletedAt.Valid {
+				fail("product #%d is deleted, restore it first", p.ID)
+				continue
+			}
+			in = catalog.FromProduct(*p)
+			in.SKU = res.SKU
+		}
+		f, err := overlay(in, row, job.Mapping).Validate()
+		if err == nil && !verified && (f.Status == models.StatusPublished || f.Status == models.StatusScheduled) {
+			err = fmt.Errorf("%w: leave status empty or draft", models.ErrUnverified)
+		}
+		if err != nil {
+			fail("%v", err)
+			continue
+		}
+
+		if !found {
+			res.Action = "create"
+			if !dryRun {
+				item := models.Product{SellerID: job.SellerID}
+				f.ApplyTo(&item)
+				if err := tx.Create(&item).Error; err != nil {
+					return rep.ImportReport, fmt.Errorf("row %d: %w", res.Row, err)
+				}
+				rev := models.ProductRevision{UserID: job.SellerID, Action: models.RevisionImport}
+				if err := models.RecordRevision(tx, nil, item, rev); err != nil {
+					return rep.ImportReport, err
+				}
+				res.ProductID = item.ID
+			}
+			rep.add(res)
+			continue
+		}
+
+		res.ProductID = p.ID
+		before := *p
+		after := *p
+		f.ApplyTo(&after)
+		if len(models.SnapshotOf(before).Diff(models.SnapshotOf(after))) == 0 {
+			res.Action = "unchanged"
+			rep.add(res)
+			continue
+		}
+		res.Action = "update"
+		if !dryRun {
+			if err := models.UpdateProduct(tx, &after, before.Version); err != nil {
+				return rep.ImportReport, fmt.Errorf("row %d (SKU %s): %w", res.Row, res.SKU, err)
+			}
+			rev := models.ProductRevision{UserID: job.SellerID, Action: models.RevisionImport}
+			if err := models.RecordRevision(tx, &before, after, rev); err != nil {
+				return rep.ImportReport, err
+			}
+		}
+		rep.add(res)
+	}
+	return rep.ImportReport, nil
+}
+
+// Preview — dry-run: что создастся, обновится и какие строки с ошибками. Ничего не пишет.
+func Preview(db *gorm.DB, job *models.ImportJob) (models.ImportReport, error) {
+	rows, err := ReadFile(job.Path)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+	return process(db, job, rows, true)
+}
+
+// apply — применить импорт одной транзакцией: либо все корректные строки, либо ничего
+func apply(db *gorm.DB, job *models.ImportJob) (models.ImportReport, error) {
+	rows, err := ReadFile(job.Path)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+	var rep models.ImportReport
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rep, err = process(tx, job, rows, false)
+		return err
+	})
+	return rep, err
+}
+
+// RunQueued — фоновая обработка: берёт задания в статусе queued по одному и применяет их.
+// Несколько процессов не возьмут одно задание: статус меняется условным UPDATE.
+func RunQueued(db *gorm.DB) error {
+	for {
+		var job models.ImportJob
+		err := db.Where("status = ?", models.ImportQueued).Order("id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res := db.Model(&models.ImportJob{}).
+			Where("id = ? AND status = ?", job.ID, models.ImportQueued).
+			Update("status", models.ImportRunning)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue // забрал другой процесс
+		}
+
+		rep, err := apply(db, &job)
+		job.Status, job.Report, job.Error = models.ImportDone, rep, ""
+		if err != nil {
+			job.Status, job.Error = models.ImportFailed, err.Error()
+		}
+		if err := db.Model(&job).Select("status", "report", "error").Updates(&job).Error; err != nil {
+			return err
+		}
+		log.Printf("import #%d: %s (%d created, %d updated, %d errors)", job.ID, job.Status, rep.Creates, rep.Updates, rep.Errors)
+		// файл больше не нужен
+		_ = os.Remove(job.Path)
+	}
+}
@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadFile читает таблицу из .csv, .xlsx или .json (выгрузка ExportJSON); первая строка — заголовок
+func ReadFile(name string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return readCSV(name)
+	case ".xlsx":
+		return readXLSX(name)
+	case ".json":
+		return readJSON(name)
+	}
+	return nil, fmt.Errorf("unsupported file type, use .csv, .xlsx or .json")
+}
+
+// readJSON — массив объектов; колонки — известные поля в порядке Columns, затем остальные ключи по алфавиту
+func readJSON(name string) ([][]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var objs []map[string]any
+	if err := json.NewDecoder(f).Decode(&objs); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+
+	var header []string
+	known := map[string]bool{}
+	for _, c := range Columns() {
+		known[c] = true
+		header = append(header, c)
+	}
+	var extra []string
+	for _, o := range objs {
+		for k := range o {
+			if !known[k] {
+				known[k] = true
+				extra = append(extra, k)
+			}
+		}
+	}
+	sort.Strings(extra)
+	header = append(header, extra...)
+
+	rows := [][]string{header}
+	for _, o := range objs {
+		row := make([]string, len(header))
+		for i, k := range header {
+			switch v := o[k].(type) {
+			case nil:
+			case string:
+				row[i] = v
+			case float64:
+				row[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readCSV понимает и "," и ";" (так сохраняет русский Excel), BOM в начале пропускается
+func readCSV(name string) ([][]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = br.Discard(3)
+	}
+	first, _ := br.Peek(4096)
+	line, _, _ := bytes.Cut(first, []byte("\n"))
+	r := csv.NewReader(br)
+	if bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+// ---- xlsx: только первый лист, значения ячеек как текст ----
+
+type xlsxRel struct {
+	ID     string `xml:"Id,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.R {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(name string) ([][]string, error) {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %w", err)
+	}
+	defer zr.Close()
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v any) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("xlsx: %s not found", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return xml.NewDecoder(io.LimitReader(rc, 64<<20)).Decode(v)
+	}
+
+	var wb xlsxWorkbook
+	if err := decode("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("xlsx: no sheets")
+	}
+	var rels struct {
+		Rels []xlsxRel `xml:"Relationship"`
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, r := range rels.Rels {
+		if r.ID == wb.Sheets[0].RID {
+			sheetPath = r.Target
+		}
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var shared []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	var sheet xlsxSheet
+	if err := decode(sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+	// Строка дополняется пустыми ячейками только до последней непустой и не шире заголовка:
+	// ячейки правее заголовка не сопоставить с полем, а "<c r="XFD1"/>" в каждой строке
+	// иначе раздул бы лист до миллиардов ячеек.
+	out := make([][]string, 0, len(sheet.Rows))
+	width, cells := maxColumns, 0
+	for _, row := range sheet.Rows {
+		var rec []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				var err error
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if col >= maxColumns {
+				return nil, fmt.Errorf("xlsx: more than %d columns", maxColumns)
+			}
+			var v string
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("xlsx: bad shared string in %s", c.Ref)
+				}
+				v = shared[n]
+			case "inlineStr":
+				v = c.Inline.String()
+			default:
+				v = c.Value
+			}
+			if v == "" || col >= width {
+				continue
+			}
+			for len(rec) <= col {
+				rec = append(rec, "")
+			}
+			rec[col] = v
+		}
+		if len(out) == 0 {
+			width = len(rec)
+		}
+		if cells += len(rec); cells > maxCells {
+			return nil, fmt.Errorf("xlsx: more than %d cells", maxCells)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// maxColumns — столбцов на листе в Excel (до XFD)
+const maxColumns = 16384
+
+// maxCells — предел ячеек листа вместе с пустыми между заполненными (~ десятки МБ строк)
+const maxCells = 4 << 20
+
+// columnIndex: "A1" → 0, "AB12" → 27
+func columnIndex(ref string) (int, error) {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+		if n > maxColumns {
+			return 0, fmt.Errorf("xlsx: bad cell reference %q", ref)
+		}
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("xlsx: bad cell reference %q", ref)
+	}
+	return n - 1, nil
+}
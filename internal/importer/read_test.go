@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+		ok   bool
+	}{
+		{"A1", 0, true},
+		{"Z9", 25, true},
+		{"AB12", 27, true},
+		{"XFD1", maxColumns - 1, true},
+		{"XFE1", 0, false},
+		{"AAAAAAA1", 0, false},
+		{"1A", 0, false},
+		{"a1", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := columnIndex(tt.ref)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, %v; want %d, ok=%v", tt.ref, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeXLSX — минимальная книга с одним листом; cells — содержимое <sheetData>
+func writeXLSX(t *testing.T, cells string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "book.xlsx")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for file, body := range map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + cells + `</sheetData></worksheet>`,
+	} {
+		w, err := zw.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadXLSX(t *testing.T) {
+	rows, err := ReadFile(writeXLSX(t, `<row><c r="A1" t="inlineStr"><is><t>sku</t></is></c><c r="C1"><v>5</v></c></row>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"sku", "", "5"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	// битые ссылки на ячейки — ошибка, а не паника или гигантская строка
+	for _, ref := range []string{"1A", "a1", "AAAAAAA1", "XFE1"} {
+		if _, err := ReadFile(writeXLSX(t, `<row><c r="`+ref+`"><v>1</v></c></row>`)); err == nil {
+			t.Errorf("cell %s: no error", ref)
+		}
+	}
+
+	// ячейка в XFD в каждой строке не растягивает строки дальше заголовка
+	sheet := `<row><c r="A1" t="inlineStr"><is><t>sku</t></is></c><c r="B1" t="inlineStr"><is><t>price</t></is></c></row>`
+	for i := 2; i <= 1000; i++ {
+		sheet += `<row><c r="A` + strconv.Itoa(i) + `"><v>1</v></c><c r="XFD` + strconv.Itoa(i) + `"><v>2</v></c></row>`
+	}
+	rows, err = ReadFile(writeXLSX(t, sheet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range rows {
+		if len(rec) > 2 {
+			t.Fatalf("row %d has %d cells, header has 2", i+1, len(rec))
+		}
+	}
+}
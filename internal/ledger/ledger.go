@@ -0,0 +1,136 @@
+// Package ledger — деньги маркетплейса по двойной записи: выручка продавцов, комиссия площадки,
+// возвраты и выплаты. Остатки не хранятся отдельно, а считаются по проводкам.
+package ledger
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// DefaultRateBP — комиссия, если не задано ни одного правила (0 — без комиссии)
+const DefaultRateBP = 0
+
+// DefaultHold — сколько выручка ждёт после получения заказа, прежде чем стать доступной к выплате.
+// Как срок возврата (disputes.ReturnWindow): пока покупатель может открыть спор, деньги не уходят продавцу.
+const DefaultHold = 30 * 24 * time.Hour
+
+// Post записывает операцию; сумма проводок в каждой валюте должна быть нулевой
+func Post(tx *gorm.DB, t *models.LedgerTransaction) error {
+	if err := balanced(t); err != nil {
+		return err
+	}
+	return tx.Create(t).Error
+}
+
+// balanced — сходится ли операция в каждой валюте
+func balanced(t *models.LedgerTransaction) error {
+	sums := map[money.Currency]int64{}
+	for _, p := range t.Postings {
+		sums[p.Currency] += p.Amount
+	}
+	for cur, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("ledger: %s transaction is off by %d %s", t.Kind, sum, cur)
+		}
+	}
+	return nil
+}
+
+// posting — проводка по счёту продавца (sellerID != 0) или площадки
+func posting(account models.LedgerAccount, sellerID uint, amount int64, cur money.Currency) models.LedgerPosting {
+	p := models.LedgerPosting{Account: account, Amount: amount, Currency: cur}
+	if sellerID != 0 {
+		p.SellerID = &sellerID
+	}
+	return p
+}
+
+// Rate — ставка комиссии для товара продавца в категории (самое узкое из правил)
+func Rate(db *gorm.DB, sellerID uint, categoryID *uint) (int, error) {
+	var rules []models.CommissionRule
+	q := db.Where("seller_id IS NULL OR seller_id = ?", sellerID)
+	if categoryID != nil {
+		q = q.Where("category_id IS NULL OR category_id = ?", *categoryID)
+	} else {
+		q = q.Where("category_id IS NULL")
+	}
+	if err := q.Find(&rules).Error; err != nil {
+		return 0, err
+	}
+	best, rate := -1, DefaultRateBP
+	for _, r := range rules {
+		// продавец важнее категории, категория важнее общего правила
+		score := 0
+		if r.SellerID != nil {
+			score += 2
+		}
+		if r.CategoryID != nil {
+			score++
+		}
+		if score > best {
+			best, rate = score, r.RateBP
+		}
+	}
+	return rate, nil
+}
+
+// Commission — комиссия с суммы по ставке в базисных пунктах, с округлением до копейки
+func Commission(amount int64, rateBP int) int64 {
+	return int64(math.Round(float64(amount) * float64(rateBP) / 10000))
+}
+
+// Balance — деньги продавца в одной валюте
+type Balance struct {
+	Currency  money.Currency
+	Pending   int64 // на удержании
+	Available int64 // можно выплатить
+	InPayout  int64 // отправлено провайдеру
+}
+
+// PendingMoney, AvailableMoney, InPayoutMoney — суммы для шаблонов
+func (b Balance) PendingMoney() money.Money   { return money.New(b.Pending, b.Currency) }
+func (b Balance) AvailableMoney() money.Money { return money.New(b.Available, b.Currency) }
+func (b Balance) InPayoutMoney() money.Money  { return money.New(b.InPayout, b.Currency) }
+
+// Balances — остатки продавца по валютам
+func Balances(db *gorm.DB, sellerID uint) ([]Balance, error) {
+	var rows []struct {
+		Account  models.LedgerAccount
+		Currency money.Currency
+		Sum      int64
+	}
+	err := db.Model(&models.LedgerPosting{}).
+		Select("account, currency, -SUM(amount) AS sum").
+		Where("seller_id = ?", sellerID).
+		Group("account, currency").
+		Order("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	var out []Balance
+	idx := map[money.Currency]int{}
+	for _, r := range rows {
+		i, ok := idx[r.Currency]
+		if !ok {
+			i = len(out)
+			idx[r.Currency] = i
+			out = append(out, Balance{Currency: r.Currency})
+		}
+		switch r.Account {
+		case models.AccountPending:
+			out[i].Pending = r.Sum
+		case models.AccountAvailable:
+			out[i].Available = r.Sum
+		case models.AccountPayout:
+			out[i].InPayout = r.Sum
+		}
+	}
+	return out, nil
+}
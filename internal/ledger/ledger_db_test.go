@@ -0,0 +1,207 @@
+package ledger
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// testDB — транзакция в пустой тестовой базе TEST_DB_DSN, после теста откатывается.
+// Без TEST_DB_DSN тест пропускается: запросы книги написаны под Postgres.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.LedgerTransaction{}, &models.LedgerPosting{}, &models.Payout{},
+		&models.SellerVerification{}, &models.VerificationDocument{}, &models.Shipment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantBalance сверяет остаток продавца из Balances и то, что его операции сходятся в ноль
+func wantBalance(t *testing.T, db *gorm.DB, step string, sellerID uint, want Balance) {
+	t.Helper()
+	list, err := Balances(db, sellerID)
+	must(t, err)
+	got := Balance{Currency: want.Currency}
+	for _, b := range list {
+		if b.Currency == want.Currency {
+			got = b
+		}
+	}
+	if got != want {
+		t.Errorf("%s: balance %+v, want %+v", step, got, want)
+	}
+	var sums []struct {
+		Currency money.Currency
+		Sum      int64
+	}
+	err = db.Model(&models.LedgerPosting{}).
+		Select("ledger_postings.currency, SUM(ledger_postings.amount) AS sum").
+		Joins("JOIN ledger_transactions t ON t.id = ledger_postings.transaction_id").
+		Where("t.seller_id = ?", sellerID).
+		Group("ledger_postings.currency").
+		Scan(&sums).Error
+	must(t, err)
+	for _, s := range sums {
+		if s.Sum != 0 {
+			t.Errorf("%s: %s postings are off by %d", step, s.Currency, s.Sum)
+		}
+	}
+}
+
+// Продажа, возвраты до и после release и полный возврат: у продавца ноль, комиссия вернулась
+func TestRefundAndReleaseDB(t *testing.T) {
+	db := testDB(t)
+	const seller, order = 900001, 900001
+	sale := saleTransaction(order, seller, money.RUB, 10000, 750)
+	must(t, Post(db, &sale))
+	wantBalance(t, db, "sale", seller, Balance{Currency: money.RUB, Pending: 9250})
+
+	must(t, Refund(db, order, seller, 3333, "r1"))
+	// комиссии возвращается 249 из 750, с продавца — остальное
+	wantBalance(t, db, "refund on hold", seller, Balance{Currency: money.RUB, Pending: 9250 - (3333 - 249)})
+	if n, err := onHold(db, sale); err != nil || n != 6166 {
+		t.Errorf("onHold = %d, %v; want 6166", n, err)
+	}
+
+	must(t, Release(db, sale))
+	must(t, Release(db, sale)) // повторный release ничего не делает
+	wantBalance(t, db, "release", seller, Balance{Currency: money.RUB, Available: 6166})
+
+	must(t, Refund(db, order, seller, 3333, "r2"))
+	wantBalance(t, db, "refund after release", seller, Balance{Currency: money.RUB, Available: 6166 - (3333 - 250)})
+	if n, err := refunded(db, sale.ID); err != nil || n != 6666 {
+		t.Errorf("refunded = %d, %v; want 6666", n, err)
+	}
+
+	// осталось 3334: больше вернуть нельзя, хотя каждый возврат сам по себе меньше продажи
+	if err := Refund(db, order, seller, 3335, "r3"); err == nil {
+		t.Error("refund over the rest of the sale accepted")
+	}
+	must(t, Refund(db, order, seller, 3334, "r3"))
+	wantBalance(t, db, "full refund", seller, Balance{Currency: money.RUB})
+	if err := Refund(db, order, seller, 1, "r4"); err == nil {
+		t.Error("refund of a fully refunded sale accepted")
+	}
+}
+
+// Выручка становится доступной только через hold после получения и без спора
+func TestReleaseDueDB(t *testing.T) {
+	db := testDB(t)
+	const seller = 900003
+	now := time.Now()
+	long, recent := now.Add(-DefaultHold-time.Hour), now.Add(-time.Hour)
+	shipments := []models.Shipment{
+		{OrderID: 900031, Status: models.ShipmentDelivered, DeliveredAt: &long},                 // пора
+		{OrderID: 900032, Status: models.ShipmentDelivered, DeliveredAt: &recent},               // ещё срок возврата
+		{OrderID: 900033, Status: models.ShipmentDelivered, DeliveredAt: &long, FrozenAt: &now}, // спор
+		{OrderID: 900034, Status: models.ShipmentShipped},                                       // в пути
+	}
+	for _, s := range shipments {
+		s.SellerID = seller
+		must(t, db.Create(&s).Error)
+		sale := saleTransaction(s.OrderID, seller, money.RUB, 1000, 0)
+		must(t, Post(db, &sale))
+	}
+
+	n, err := ReleaseDue(db, DefaultHold, now)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("released %d sales, want 1", n)
+	}
+	wantBalance(t, db, "release due", seller, Balance{Currency: money.RUB, Pending: 3000, Available: 1000})
+	for order, want := range map[uint]bool{900031: true, 900032: false, 900033: false, 900034: false} {
+		if done, err := Released(db, order, seller); err != nil || done != want {
+			t.Errorf("order %d: released = %v, %v; want %v", order, done, err, want)
+		}
+	}
+	if n, err := ReleaseDue(db, DefaultHold, now); err != nil || n != 0 {
+		t.Errorf("second run released %d, %v", n, err)
+	}
+}
+
+// Выплата: только с реквизитами; неудача возвращает деньги в доступные, успех их списывает
+func TestPayoutDB(t *testing.T) {
+	db := testDB(t)
+	const seller, order = 900002, 900002
+	v := models.SellerVerification{SellerID: seller, Status: models.VerificationApproved}
+	must(t, db.Create(&v).Error)
+	sale := saleTransaction(order, seller, money.EUR, 5000, 500)
+	must(t, Post(db, &sale))
+	must(t, Release(db, sale))
+	other := saleTransaction(order+1, seller, money.EUR, 2000, 0) // ещё на удержании
+	must(t, Post(db, &other))
+	wantBalance(t, db, "sales", seller, Balance{Currency: money.EUR, Pending: 2000, Available: 4500})
+
+	due := func() *models.Payout {
+		t.Helper()
+		list, err := available(db)
+		must(t, err)
+		for _, p := range list {
+			if p.SellerID == seller {
+				p.Status, p.Provider = models.PayoutPending, "test"
+				return &p
+			}
+		}
+		return nil
+	}
+	if p := due(); p != nil {
+		t.Fatalf("payout %+v without bank details", p)
+	}
+	must(t, db.Model(&v).Updates(map[string]any{"bik": "044525225", "account": "40702810400000000001"}).Error)
+	p := due()
+	if p == nil || p.Amount != 4500 {
+		t.Fatalf("due payout %+v, want 4500", p)
+	}
+
+	must(t, start(db, p))
+	wantBalance(t, db, "payout sent", seller, Balance{Currency: money.EUR, Pending: 2000, InPayout: 4500})
+	again := &models.Payout{SellerID: seller, Currency: money.EUR, Status: models.PayoutPending, Provider: "test"}
+	if err := start(db, again); !errors.Is(err, errNothing) {
+		t.Errorf("second start = %v, want errNothing", err)
+	}
+
+	must(t, finish(db, p, "", errors.New("declined")))
+	must(t, finish(db, p, "ref", nil)) // результат уже записан — ничего не меняется
+	wantBalance(t, db, "payout failed", seller, Balance{Currency: money.EUR, Pending: 2000, Available: 4500})
+
+	p = due()
+	must(t, start(db, p))
+	must(t, finish(db, p, "ref", nil))
+	wantBalance(t, db, "payout paid", seller, Balance{Currency: money.EUR, Pending: 2000})
+
+	// возврат после выплаты уводит доступный остаток в минус — он зачтётся из следующих продаж
+	must(t, Refund(db, order, seller, 1000, "r1"))
+	wantBalance(t, db, "refund after payout", seller, Balance{Currency: money.EUR, Pending: 2000, Available: -900})
+	must(t, Release(db, other))
+	wantBalance(t, db, "next release", seller, Balance{Currency: money.EUR, Available: 1100})
+	if p := due(); p == nil || p.Amount != 1100 {
+		t.Errorf("due payout %+v, want 1100", p)
+	}
+}
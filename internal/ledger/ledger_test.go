@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"testing"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []models.LedgerPosting
+		ok       bool
+	}{
+		{"empty", nil, true},
+		{"two currencies", []models.LedgerPosting{
+			posting(models.AccountCash, 0, 100, money.RUB), posting(models.AccountPending, 7, -100, money.RUB),
+			posting(models.AccountCash, 0, 5, money.USD), posting(models.AccountPending, 7, -5, money.USD),
+		}, true},
+		{"off by one", []models.LedgerPosting{
+			posting(models.AccountCash, 0, 100, money.RUB), posting(models.AccountPending, 7, -99, money.RUB),
+		}, false},
+		{"zero in total, not per currency", []models.LedgerPosting{
+			posting(models.AccountCash, 0, 100, money.RUB), posting(models.AccountPending, 7, -100, money.USD),
+		}, false},
+	}
+	for _, tt := range tests {
+		err := balanced(&models.LedgerTransaction{Kind: models.LedgerSale, Postings: tt.postings})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: balanced = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// Каждая операция, которую собирает книга, сходится в ноль в своей валюте
+func TestTransactionsBalance(t *testing.T) {
+	sale := saleTransaction(1, 7, money.RUB, 10001, 751)
+	sale.ID = 1
+	p := &models.Payout{Base: models.Base{ID: 2}, SellerID: 7, Amount: 4500, Currency: money.EUR}
+	list := []models.LedgerTransaction{
+		sale,
+		saleTransaction(2, 7, money.USD, 999, 0),
+		releaseTransaction(sale, 9250),
+		payoutTransaction(p),
+		payoutResultTransaction(p, false),
+		payoutResultTransaction(p, true),
+	}
+	for _, before := range []int64{0, 1, 3333, 10000} {
+		for _, account := range []models.LedgerAccount{models.AccountPending, models.AccountAvailable} {
+			if r, err := refundTransaction(sale, before, 1, account); err == nil {
+				list = append(list, r)
+			} else {
+				t.Errorf("refund 1 after %d: %v", before, err)
+			}
+		}
+	}
+	for _, tr := range list {
+		if err := balanced(&tr); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
+
+// Вместе с прошлыми возвратами нельзя вернуть больше продажи
+func TestRefundCap(t *testing.T) {
+	sale := saleTransaction(1, 7, money.RUB, 10000, 750)
+	tests := []struct {
+		before, amount int64
+		ok             bool
+	}{
+		{0, 10000, true},
+		{0, 10001, false},
+		{3333, 6667, true},
+		{3333, 6668, false},
+		{10000, 1, false},
+		{0, 0, false},
+		{0, -5, false},
+	}
+	for _, tt := range tests {
+		_, err := refundTransaction(sale, tt.before, tt.amount, models.AccountPending)
+		if (err == nil) != tt.ok {
+			t.Errorf("refund %d after %d: %v, want ok %v", tt.amount, tt.before, err, tt.ok)
+		}
+	}
+}
+
+// Комиссия возвращается пропорционально и при полном возврате сходится до копейки
+func TestRefundCommission(t *testing.T) {
+	for _, tt := range []struct {
+		gross, commission int64
+		parts             []int64
+	}{
+		{10000, 750, []int64{10000}},
+		{10000, 750, []int64{3333, 3333, 3334}},
+		{10000, 750, []int64{1, 1, 9998}},
+		{999, 333, []int64{333, 333, 333}},
+		{101, 7, []int64{50, 50, 1}},
+		{12345, 0, []int64{12000, 345}},
+	} {
+		sale := saleTransaction(1, 7, money.USD, tt.gross, tt.commission)
+		var before, back, seller int64
+		for _, part := range tt.parts {
+			r, err := refundTransaction(sale, before, part, models.AccountPending)
+			if err != nil {
+				t.Fatalf("%d/%d %v: %v", tt.gross, tt.commission, tt.parts, err)
+			}
+			before += part
+			back += r.Postings[1].Amount
+			seller += r.Postings[2].Amount
+			if back < 0 || back > tt.commission {
+				t.Errorf("%d/%d %v: commission returned %d", tt.gross, tt.commission, tt.parts, back)
+			}
+		}
+		if back != tt.commission || seller != tt.gross-tt.commission {
+			t.Errorf("%d/%d %v: full refund returned commission %d and seller %d", tt.gross, tt.commission, tt.parts, back, seller)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// Provider — банк или платёжный сервис, который переводит деньги продавцу.
+// Pay должен быть идемпотентным по payout.ID: после сбоя та же выплата отправляется повторно.
+type Provider interface {
+	Name() string
+	Pay(ctx context.Context, payout models.Payout, bank models.SellerVerification) (reference string, err error)
+}
+
+// Fake — провайдер для разработки: ничего не переводит, только пишет в лог
+type Fake struct{}
+
+func (Fake) Name() string { return "fake" }
+
+func (Fake) Pay(_ context.Context, p models.Payout, bank models.SellerVerification) (string, error) {
+	log.Printf("payout #%d: %s to seller #%d, account %s BIK %s", p.ID, p.Money(), p.SellerID, bank.Account, bank.BIK)
+	return fmt.Sprintf("fake-%d", p.ID), nil
+}
+
+// ProviderFromEnv — провайдер выплат по PAYOUT_PROVIDER; пока есть только fake
+func ProviderFromEnv() (Provider, error) {
+	switch name := os.Getenv("PAYOUT_PROVIDER"); name {
+	case "", "fake":
+		return Fake{}, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYOUT_PROVIDER %q", name)
+	}
+}
+
+// available — доступные остатки проверенных продавцов, которые пора выплатить.
+// Без расчётного счёта и БИК выплату не отправить — такие остатки ждут, пока продавец их заполнит.
+func available(db *gorm.DB) ([]models.Payout, error) {
+	var rows []models.Payout
+	err := db.Model(&models.LedgerPosting{}).
+		Select("ledger_postings.seller_id, ledger_postings.currency, -SUM(ledger_postings.amount) AS amount").
+		Joins("JOIN seller_verifications v ON v.seller_id = ledger_postings.seller_id AND v.status = ? AND v.account <> '' AND v.bik <> ''",
+			models.VerificationApproved).
+		Where("ledger_postings.account = ?", models.AccountAvailable).
+		Group("ledger_postings.seller_id, ledger_postings.currency").
+		Having("-SUM(ledger_postings.amount) > 0").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// payoutLock — первый ключ pg_advisory_xact_lock для выплат (второй — продавец)
+const payoutLock = 4601
+
+// errNothing — пока считали остатки, их успел выплатить параллельный прогон
+var errNothing = errors.New("nothing to pay out")
+
+// start создаёт выплату и переносит сумму из доступных в отправленные.
+// Остаток пересчитывается под блокировкой продавца, чтобы два прогона не выплатили его дважды.
+func start(db *gorm.DB, p *models.Payout) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", payoutLock, p.SellerID).Error; err != nil {
+			return err
+		}
+		var amount int64
+		err := tx.Model(&models.LedgerPosting{}).
+			Where("seller_id = ? AND account = ? AND currency = ?", p.SellerID, models.AccountAvailable, p.Currency).
+			Select("COALESCE(-SUM(amount), 0)").
+			Scan(&amount).Error
+		if err != nil {
+			return err
+		}
+		if amount <= 0 {
+			return errNothing
+		}
+		p.Amount = amount
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		t := payoutTransaction(p)
+		return Post(tx, &t)
+	})
+}
+
+// payoutTransaction — операция payout: сумма выплаты из доступных в отправленные
+func payoutTransaction(p *models.Payout) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Kind:     models.LedgerPayout,
+		SellerID: &p.SellerID,
+		PayoutID: &p.ID,
+		Memo:     fmt.Sprintf("payout #%d", p.ID),
+		Postings: []models.LedgerPosting{
+			posting(models.AccountAvailable, p.SellerID, p.Amount, p.Currency),
+			posting(models.AccountPayout, p.SellerID, -p.Amount, p.Currency),
+		},
+	}
+}
+
+// payoutResultTransaction — итог выплаты: деньги ушли со счёта площадки или вернулись продавцу в доступные
+func payoutResultTransaction(p *models.Payout, failed bool) models.LedgerTransaction {
+	kind, to := models.LedgerPayoutPaid, posting(models.AccountCash, 0, -p.Amount, p.Currency)
+	if failed {
+		kind, to = models.LedgerPayoutFailed, posting(models.AccountAvailable, p.SellerID, -p.Amount, p.Currency)
+	}
+	return models.LedgerTransaction{
+		Kind:     kind,
+		SellerID: &p.SellerID,
+		PayoutID: &p.ID,
+		Memo:     fmt.Sprintf("payout #%d", p.ID),
+		Postings: []models.LedgerPosting{posting(models.AccountPayout, p.SellerID, p.Amount, p.Currency), to},
+	}
+}
+
+// finish записывает результат провайдера в выплату и книгу
+func finish(db *gorm.DB, p *models.Payout, ref string, payErr error) error {
+	cols := map[string]any{"status": models.PayoutPaid, "reference": ref, "paid_at": time.Now(), "error": ""}
+	if payErr != nil {
+		cols = map[string]any{"status": models.PayoutFailed, "error": payErr.Error()}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(p).Where("status = ?", models.PayoutPending).Updates(cols)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // результат уже записал параллельный прогон
+		}
+		t := payoutResultTransaction(p, payErr != nil)
+		return Post(tx, &t)
+	})
+}
+
+// RunReport — итог прогона выплат
+type RunReport struct {
+	Paid   int
+	Failed int
+	Total  map[money.Currency]int64 // сколько выплачено
+}
+
+func (r RunReport) String() string {
+	return fmt.Sprintf("paid=%d failed=%d total=%v", r.Paid, r.Failed, r.Total)
+}
+
+// RunPayouts выплачивает доступные остатки проверенных продавцов: одна выплата на продавца и валюту.
+// Сначала повторяет выплаты, зависшие в pending после сбоя прошлого прогона.
+func RunPayouts(ctx context.Context, db *gorm.DB, provider Provider) (RunReport, error) {
+	rep := RunReport{Total: map[money.Currency]int64{}}
+	var queue []models.Payout
+	if err := db.Where("status = ?", models.PayoutPending).Order("id").Find(&queue).Error; err != nil {
+		return rep, err
+	}
+	due, err := available(db)
+	if err != nil {
+		return rep, err
+	}
+	for _, p := range due {
+		p.Status, p.Provider = models.PayoutPending, provider.Name()
+		if err := start(db, &p); errors.Is(err, errNothing) {
+			continue
+		} else if err != nil {
+			return rep, fmt.Errorf("seller #%d: %w", p.SellerID, err)
+		}
+		queue = append(queue, p)
+	}
+	for _, p := range queue {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		var bank models.SellerVerification
+		if err := db.First(&bank, "seller_id = ?", p.SellerID).Error; err != nil {
+			return rep, err
+		}
+		ref, payErr := provider.Pay(ctx, p, bank)
+		if err := finish(db, &p, ref, payErr); err != nil {
+			return rep, fmt.Errorf("payout #%d: %w", p.ID, err)
+		}
+		if payErr != nil {
+			log.Printf("payout #%d failed: %v", p.ID, payErr)
+			rep.Failed++
+			continue
+		}
+		rep.Paid++
+		rep.Total[p.Currency] += p.Amount
+	}
+	return rep, nil
+}
@@ -0,0 +1,245 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+// RecordSale проводит оплаченный заказ: по каждому продавцу отдельная операция sale —
+// деньги покупателя на счёт площадки, комиссия площадке, остальное продавцу на удержание.
+// Вызывается в транзакции оформления заказа.
+func RecordSale(tx *gorm.DB, order *models.Order) error {
+	ids := make([]uint, len(order.Items))
+	for i, it := range order.Items {
+		ids[i] = it.ProductID
+	}
+	var products []models.Product
+	if err := tx.Unscoped().Select("id", "category_id").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return err
+	}
+	category := map[uint]*uint{}
+	for _, p := range products {
+		category[p.ID] = p.CategoryID
+	}
+
+	type share struct{ gross, commission int64 }
+	shares := map[uint]*share{}
+	var sellers []uint // порядок операций — как у позиций заказа
+	for _, it := range order.Items {
+		rate, err := Rate(tx, it.SellerID, category[it.ProductID])
+		if err != nil {
+			return err
+		}
+		s := shares[it.SellerID]
+		if s == nil {
+			s = &share{}
+			shares[it.SellerID] = s
+			sellers = append(sellers, it.SellerID)
+		}
+		sub := it.Subtotal(order.Currency).Amount
+		s.gross += sub
+		s.commission += Commission(sub, rate)
+	}
+	for _, sellerID := range sellers {
+		s := shares[sellerID]
+		t := saleTransaction(order.ID, sellerID, order.Currency, s.gross, s.commission)
+		if err := Post(tx, &t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saleTransaction — операция sale продавца по заказу
+func saleTransaction(orderID, sellerID uint, cur money.Currency, gross, commission int64) models.LedgerTransaction {
+	return models.LedgerTransaction{
+		Kind:     models.LedgerSale,
+		OrderID:  &orderID,
+		SellerID: &sellerID,
+		Memo:     fmt.Sprintf("order #%d", orderID),
+		Postings: []models.LedgerPosting{
+			posting(models.AccountCash, 0, gross, cur),
+			posting(models.AccountCommission, 0, -commission, cur),
+			posting(models.AccountPending, sellerID, -(gross - commission), cur),
+		},
+	}
+}
+
+// onHold — сколько по продаже ещё на удержании у продавца (выручка минус возвраты до release)
+func onHold(tx *gorm.DB, sale models.LedgerTransaction) (int64, error) {
+	var sum int64
+	err := tx.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_transactions t ON t.id = ledger_postings.transaction_id").
+		Where("ledger_postings.account = ? AND (t.id = ? OR t.sale_id = ?)", models.AccountPending, sale.ID, sale.ID).
+		Select("COALESCE(-SUM(ledger_postings.amount), 0)").
+		Scan(&sum).Error
+	return sum, err
+}
+
+// released — закончилось ли удержание по продаже
+func released(tx *gorm.DB, saleID uint) (bool, error) {
+	var n int64
+	err := tx.Model(&models.LedgerTransaction{}).Where("kind = ? AND sale_id = ?", models.LedgerRelease, saleID).Count(&n).Error
+	return n > 0, err
+}
+
+// lockSale — блокировка продажи: release и refund по ней идут по очереди
+func lockSale(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// Release переводит остаток продажи с удержания в доступные к выплате
+func Release(tx *gorm.DB, sale models.LedgerTransaction) error {
+	if err := lockSale(tx).Select("id").First(&models.LedgerTransaction{}, sale.ID).Error; err != nil {
+		return err
+	}
+	if done, err := released(tx, sale.ID); err != nil || done {
+		return err
+	}
+	amount, err := onHold(tx, sale)
+	if err != nil {
+		return err
+	}
+	if len(sale.Postings) == 0 {
+		if err := tx.Where("transaction_id = ?", sale.ID).Find(&sale.Postings).Error; err != nil {
+			return err
+		}
+	}
+	t := releaseTransaction(sale, amount)
+	return Post(tx, &t)
+}
+
+// releaseTransaction — операция release: amount с удержания в доступные
+func releaseTransaction(sale models.LedgerTransaction, amount int64) models.LedgerTransaction {
+	cur := sale.Postings[0].Currency
+	return models.LedgerTransaction{
+		Kind:     models.LedgerRelease,
+		OrderID:  sale.OrderID,
+		SellerID: sale.SellerID,
+		SaleID:   &sale.ID,
+		Memo:     sale.Memo,
+		Postings: []models.LedgerPosting{
+			posting(models.AccountPending, *sale.SellerID, amount, cur),
+			posting(models.AccountAvailable, *sale.SellerID, -amount, cur),
+		},
+	}
+}
+
+// ReleaseDue закрывает удержание по продажам, которые покупатель получил раньше hold назад
+// и по которым нет спора (заморозки). Заказы, оформленные до появления книги, операции sale
+// не имеют — по ним ничего не делается. Возвращает число продаж.
+func ReleaseDue(db *gorm.DB, hold time.Duration, now time.Time) (int, error) {
+	var sales []models.LedgerTransaction
+	err := db.Preload("Postings").
+		Joins("JOIN shipments s ON s.order_id = ledger_transactions.order_id AND s.seller_id = ledger_transactions.seller_id").
+		Where("ledger_transactions.kind = ? AND s.status = ? AND s.delivered_at <= ? AND s.frozen_at IS NULL",
+			models.LedgerSale, models.ShipmentDelivered, now.Add(-hold)).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_transactions r WHERE r.kind = ? AND r.sale_id = ledger_transactions.id)", models.LedgerRelease).
+		Order("ledger_transactions.id").Limit(500).
+		Find(&sales).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, sale := range sales {
+		if err := db.Transaction(func(tx *gorm.DB) error { return Release(tx, sale) }); err != nil {
+			return i, fmt.Errorf("release sale #%d: %w", sale.ID, err)
+		}
+	}
+	return len(sales), nil
+}
+
+// Sold — есть ли в книге продажа по части заказа продавца (у заказов до появления книги её нет)
+func Sold(tx *gorm.DB, orderID, sellerID uint) (bool, error) {
+	var n int64
+	err := tx.Model(&models.LedgerTransaction{}).
+		Where("kind = ? AND order_id = ? AND seller_id = ?", models.LedgerSale, orderID, sellerID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// Released — закончилось ли удержание по части заказа продавца (без операции sale — нет)
+func Released(tx *gorm.DB, orderID, sellerID uint) (bool, error) {
+	var n int64
+	err := tx.Model(&models.LedgerTransaction{}).
+		Where("kind = ? AND order_id = ? AND seller_id = ?", models.LedgerRelease, orderID, sellerID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// refunded — сколько по продаже уже вернули покупателю
+func refunded(tx *gorm.DB, saleID uint) (int64, error) {
+	var sum int64
+	err := tx.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_transactions t ON t.id = ledger_postings.transaction_id").
+		Where("t.kind = ? AND t.sale_id = ? AND ledger_postings.account = ?", models.LedgerRefund, saleID, models.AccountCash).
+		Select("COALESCE(-SUM(ledger_postings.amount), 0)").
+		Scan(&sum).Error
+	return sum, err
+}
+
+// Refund возвращает покупателю amount по заказу у продавца: комиссия возвращается пропорционально,
+// остальное списывается с удержания (или с доступного остатка, если удержание уже закончилось —
+// тогда остаток может уйти в минус и зачтётся из следующих продаж).
+// Вместе с прошлыми возвратами нельзя вернуть больше, чем заплатил покупатель.
+func Refund(tx *gorm.DB, orderID, sellerID uint, amount int64, memo string) error {
+	var sale models.LedgerTransaction
+	err := lockSale(tx).Preload("Postings").
+		Where("kind = ? AND order_id = ? AND seller_id = ?", models.LedgerSale, orderID, sellerID).
+		First(&sale).Error
+	if err != nil {
+		return fmt.Errorf("ledger: no sale for order #%d seller #%d: %w", orderID, sellerID, err)
+	}
+	before, err := refunded(tx, sale.ID)
+	if err != nil {
+		return err
+	}
+	account := models.AccountPending
+	if done, err := released(tx, sale.ID); err != nil {
+		return err
+	} else if done {
+		account = models.AccountAvailable
+	}
+	t, err := refundTransaction(sale, before, amount, account)
+	if err != nil {
+		return err
+	}
+	t.Memo = memo
+	return Post(tx, &t)
+}
+
+// refundTransaction — операция refund на amount, если по продаже уже вернули before.
+// Комиссия считается от всех возвратов вместе, чтобы при полном возврате она вернулась до копейки.
+func refundTransaction(sale models.LedgerTransaction, before, amount int64, account models.LedgerAccount) (models.LedgerTransaction, error) {
+	var gross, commission int64
+	for _, p := range sale.Postings {
+		switch p.Account {
+		case models.AccountCash:
+			gross = p.Amount
+		case models.AccountCommission:
+			commission = -p.Amount
+		}
+	}
+	if left := gross - before; amount <= 0 || amount > left {
+		return models.LedgerTransaction{}, fmt.Errorf("ledger: refund %d is outside 1..%d", amount, left)
+	}
+	cur := sale.Postings[0].Currency
+	back := commission*(before+amount)/gross - commission*before/gross
+	sellerID := *sale.SellerID
+	return models.LedgerTransaction{
+		Kind:     models.LedgerRefund,
+		OrderID:  sale.OrderID,
+		SellerID: &sellerID,
+		SaleID:   &sale.ID,
+		Postings: []models.LedgerPosting{
+			posting(models.AccountCash, 0, -amount, cur),
+			posting(models.AccountCommission, 0, back, cur),
+			posting(account, sellerID, amount-back, cur),
+		},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIScope — право ключа API
+type APIScope string
+
+const (
+	ScopeProductsRead  APIScope = "products:read"
+	ScopeProductsWrite APIScope = "products:write"
+)
+
+// APIScopes — все права в порядке показа
+var APIScopes = []APIScope{ScopeProductsRead, ScopeProductsWrite}
+
+// apiKeyPrefix — начало каждого ключа, чтобы его было видно в логах и сканерах секретов
+const apiKeyPrefix = "mpk_"
+
+// APIKey — таблица api_keys: ключи продавца для /api/v1/seller.
+// Сам ключ не храним — только SHA-256; показываем его один раз при создании.
+type APIKey struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	SellerID   uint       `gorm:"index;not null"`
+	Name       string     `gorm:"not null"`
+	Prefix     string     `gorm:"type:varchar(16);not null"`          // первые символы ключа, чтобы продавец узнал его в списке
+	Hash       string     `gorm:"type:char(64);not null;uniqueIndex"` // sha256(ключ) в hex
+	Scopes     []APIScope `gorm:"type:jsonb;serializer:json;not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time `gorm:"index"`
+}
+
+// Allows — есть ли у ключа право scope
+func (k APIKey) Allows(scope APIScope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// HashAPIKey — как ключ хранится в БД
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey создаёт ключ (ещё не сохранённый) и возвращает его открытое значение
+func NewAPIKey(sellerID uint, name string, scopes []APIScope) (APIKey, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", err
+	}
+	plain := apiKeyPrefix + hex.EncodeToString(b)
+	return APIKey{
+		SellerID: sellerID,
+		Name:     name,
+		Prefix:   plain[:len(apiKeyPrefix)+6],
+		Hash:     HashAPIKey(plain),
+		Scopes:   scopes,
+	}, plain, nil
+}
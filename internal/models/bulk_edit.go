@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// BulkEditItem — товар из массовой правки и его состояние до неё
+type BulkEditItem struct {
+	ProductID uint            `json:"product_id"`
+	Before    ProductSnapshot `json:"before"`
+	Version   int             `json:"version"` // версия после правки: откат только если товар с тех пор не меняли
+}
+
+// BulkEdit — таблица bulk_edits: применённое массовое действие, которое можно отменить в течение окна
+type BulkEdit struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	SellerID  uint           `gorm:"index;not null"`
+	Action    string         `gorm:"type:varchar(32);not null"`
+	Value     string         // параметр действия как его ввели (процент, сумма, остаток, категория)
+	Items     []BulkEditItem `gorm:"type:jsonb;serializer:json"`
+	UndoneAt  *time.Time
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Category — таблица categories: плоский справочник категорий товаров (ведётся через mpctl)
+type Category struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Name      string `gorm:"not null;uniqueIndex"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"marketplace/internal/money"
+)
+
+// DisputeReason — с чем покупатель пришёл
+type DisputeReason string
+
+const (
+	ReasonNotReceived    DisputeReason = "not_received"
+	ReasonDamaged        DisputeReason = "damaged"
+	ReasonNotAsDescribed DisputeReason = "not_as_described"
+	ReasonWrongItem      DisputeReason = "wrong_item"
+	ReasonChangedMind    DisputeReason = "changed_mind"
+)
+
+// DisputeReasons — причины в порядке показа в форме
+var DisputeReasons = []DisputeReason{ReasonNotReceived, ReasonDamaged, ReasonNotAsDescribed, ReasonWrongItem, ReasonChangedMind}
+
+// DisputeStatus — open → (return_requested → returning) → resolved; при отказе продавца — escalated → resolved
+type DisputeStatus string
+
+const (
+	DisputeOpen            DisputeStatus = "open"             // ждёт ответа продавца
+	DisputeReturnRequested DisputeStatus = "return_requested" // продавец согласен на возврат, покупатель отправляет товар
+	DisputeReturning       DisputeStatus = "returning"        // товар едет обратно к продавцу
+	DisputeEscalated       DisputeStatus = "escalated"        // продавец отказал — решает площадка
+	DisputeResolved        DisputeStatus = "resolved"
+)
+
+// DisputeStatuses — для фильтра в очереди
+var DisputeStatuses = []DisputeStatus{DisputeEscalated, DisputeOpen, DisputeReturnRequested, DisputeReturning, DisputeResolved}
+
+// DisputeOutcome — чем закончился спор
+type DisputeOutcome string
+
+const (
+	OutcomeRefund    DisputeOutcome = "refund"    // деньги вернули (если товар вернулся — он снова на складе)
+	OutcomeRejected  DisputeOutcome = "rejected"  // площадка отказала
+	OutcomeWithdrawn DisputeOutcome = "withdrawn" // покупатель отозвал
+)
+
+// Dispute — таблица disputes: возврат или спор по позиции заказа.
+// Пока спор открыт, выручка продавца по заказу заморожена (см. Shipment.FrozenAt).
+type Dispute struct {
+	Base
+	OrderID     uint          `gorm:"index;not null"`
+	OrderItemID uint          `gorm:"not null;uniqueIndex:idx_dispute_active,where:status <> 'resolved'"` // один открытый спор на позицию
+	BuyerID     uint          `gorm:"index;not null"`
+	SellerID    uint          `gorm:"index;not null"`
+	Reason      DisputeReason `gorm:"type:varchar(24);not null"`
+	Qty         int           `gorm:"not null"` // сколько штук позиции
+	Status      DisputeStatus `gorm:"type:varchar(16);not null;index"`
+
+	// обратная отправка
+	ReturnCarrier    string
+	ReturnTracking   string
+	ReturnShippedAt  *time.Time
+	ReturnReceivedAt *time.Time
+
+	Outcome      DisputeOutcome `gorm:"type:varchar(16)"`
+	RefundAmount int64          `gorm:"not null;default:0"` // в валюте заказа
+	Restocked    bool           `gorm:"not null;default:false"`
+	ResolvedAt   *time.Time
+	ResolvedBy   *uint // nil — решили продавец с покупателем без площадки
+
+	Item     OrderItem `gorm:"foreignKey:OrderItemID"`
+	Messages []DisputeMessage
+}
+
+// Active — спор ещё не закрыт
+func (d Dispute) Active() bool { return d.Status != DisputeResolved }
+
+// Refunded — сколько вернули покупателю; cur — валюта заказа
+func (d Dispute) Refunded(cur money.Currency) money.Money { return money.New(d.RefundAmount, cur) }
+
+// DisputeMessage — таблица dispute_messages: переписка по спору.
+// AuthorID = 0 — системная запись о смене статуса.
+type DisputeMessage struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	DisputeID uint           `gorm:"index;not null"`
+	AuthorID  uint           `gorm:"not null;default:0"`
+	Body      string         `gorm:"type:text"`
+	Photos    []DisputePhoto `gorm:"foreignKey:MessageID"`
+}
+
+// DisputePhoto — таблица dispute_photos: фото к сообщению (путь в uploads)
+type DisputePhoto struct {
+	ID        uint   `gorm:"primaryKey"`
+	MessageID uint   `gorm:"index;not null"`
+	Path      string `gorm:"not null"`
+}
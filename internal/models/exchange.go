@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ExchangeState — таблица exchange_states: обмен заказами с 1С по продавцу.
+// Выгруженные заказы считаются полученными только после mode=success,
+// до этого 1С получит их снова.
+type ExchangeState struct {
+	SellerID    uint `gorm:"primaryKey"`
+	OrdersSent  uint `gorm:"not null;default:0"` // последний заказ, отданный в mode=query
+	OrdersAcked uint `gorm:"not null;default:0"` // последний заказ, получение которого 1С подтвердила
+	UpdatedAt   time.Time
+}
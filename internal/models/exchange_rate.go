@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"marketplace/internal/money"
+)
+
+// ExchangeRate — таблица exchange_rates: сколько Quote за 1 Base
+type ExchangeRate struct {
+	ID        uint           `gorm:"primaryKey"`
+	Base      money.Currency `gorm:"type:varchar(3);not null;uniqueIndex:idx_rate_pair"`
+	Quote     money.Currency `gorm:"type:varchar(3);not null;uniqueIndex:idx_rate_pair"`
+	Rate      float64        `gorm:"not null"`
+	Source    string         `gorm:"type:varchar(32)"` // "manual", "file", имя провайдера
+	UpdatedAt time.Time
+}
@@ -0,0 +1,51 @@
+package models
+
+import "marketplace/internal/money"
+
+// ImportStatus — этап импорта: загрузили файл → сопоставили колонки и посмотрели dry-run → применяем
+type ImportStatus string
+
+const (
+	ImportUploaded  ImportStatus = "uploaded"
+	ImportPreviewed ImportStatus = "previewed"
+	ImportQueued    ImportStatus = "queued"
+	ImportRunning   ImportStatus = "running"
+	ImportDone      ImportStatus = "done"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportRow — итог по одной строке файла
+type ImportRow struct {
+	Row       int    `json:"row"` // номер строки в файле (заголовок — 1)
+	SKU       string `json:"sku"`
+	Action    string `json:"action"` // create, update, unchanged, error
+	ProductID uint   `json:"product_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportReport — сводка dry-run или применения
+type ImportReport struct {
+	Creates   int         `json:"creates"`
+	Updates   int         `json:"updates"`
+	Unchanged int         `json:"unchanged"`
+	Errors    int         `json:"errors"`
+	Rows      []ImportRow `json:"rows"` // ошибки и изменения, не больше ImportReportRows
+}
+
+// ImportReportRows — сколько строк отчёта храним и показываем
+const ImportReportRows = 500
+
+// ImportJob — таблица import_jobs: загруженный продавцом файл и его обработка
+type ImportJob struct {
+	Base
+	SellerID uint           `gorm:"index;not null"`
+	FileName string         `gorm:"not null"` // как назывался у продавца
+	Path     string         `gorm:"not null"` // где лежит у нас (не раздаётся наружу)
+	Status   ImportStatus   `gorm:"type:varchar(16);not null;index"`
+	Header   []string       `gorm:"type:jsonb;serializer:json"`
+	Rows     int            // строк данных без заголовка
+	Mapping  map[string]int `gorm:"type:jsonb;serializer:json"` // поле товара → номер колонки
+	Currency money.Currency `gorm:"type:varchar(3)"`            // для строк без валюты
+	Report   ImportReport   `gorm:"type:jsonb;serializer:json"`
+	Error    string         `gorm:"type:text"` // почему весь импорт упал
+}
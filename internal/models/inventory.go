@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MovementReason — почему изменился остаток
+type MovementReason string
+
+const (
+	MoveInitial  MovementReason = "initial"  // остаток при создании товара
+	MoveManual   MovementReason = "manual"   // правка в форме товара
+	MoveRollback MovementReason = "rollback" // откат к ревизии
+	MoveImport   MovementReason = "import"
+	MoveExchange MovementReason = "exchange" // обмен с 1С
+	MoveAPI      MovementReason = "api"
+	MoveBulk     MovementReason = "bulk"
+	MoveUndo     MovementReason = "undo" // отмена массового действия
+	MoveSale     MovementReason = "sale"
+	MoveRefund   MovementReason = "refund"   // возврат товара на склад
+	MoveCancel   MovementReason = "cancel"   // заказ не оплачен, резерв вернулся на склад
+	MoveTransfer MovementReason = "transfer" // перемещение между складами, общий остаток не меняется
+
+	// корректировки со страницы движения товара
+	MoveReceived MovementReason = "received"
+	MoveRecount  MovementReason = "recount"
+	MoveDamaged  MovementReason = "damaged"
+	MoveLost     MovementReason = "lost"
+)
+
+// AdjustReasons — причины ручной корректировки остатка
+var AdjustReasons = []MovementReason{MoveReceived, MoveRecount, MoveDamaged, MoveLost}
+
+// revisionReasons — причина движения для правок, которые пишут ревизию
+var revisionReasons = map[RevisionAction]MovementReason{
+	RevisionCreate:   MoveInitial,
+	RevisionUpdate:   MoveManual,
+	RevisionRollback: MoveRollback,
+	RevisionImport:   MoveImport,
+	RevisionExchange: MoveExchange,
+	RevisionAPI:      MoveAPI,
+	RevisionBulk:     MoveBulk,
+	RevisionUndo:     MoveUndo,
+}
+
+// InventoryMovement — таблица inventory_movements: журнал изменений остатка.
+// Balance — общий остаток после движения, т.е. Product.Stock = Balance последней записи.
+// Изменение, пришедшееся на несколько складов, пишется несколькими движениями.
+type InventoryMovement struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	ProductID   uint           `gorm:"index;not null"`
+	WarehouseID *uint          `gorm:"index"`
+	Delta       int            `gorm:"not null"`
+	Balance     int            `gorm:"not null"`
+	Reason      MovementReason `gorm:"type:varchar(16);not null"`
+	ActorID     uint           `gorm:"not null;default:0"` // кто изменил; 0 — система
+	OrderID     *uint          `gorm:"index"`
+	Note        string
+}
+
+// ErrInsufficientStock — списание увело бы остаток ниже нуля
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// MoveStock атомарно меняет остаток на m.Delta (остаток не уходит ниже нуля, версия растёт),
+// раскладывает его по складам (m.WarehouseID — конкретный склад) и пишет движения — по одному на склад.
+func MoveStock(tx *gorm.DB, m InventoryMovement) ([]InventoryMovement, error) {
+	p := Product{Base: Base{ID: m.ProductID}}
+	res := tx.Model(&p).
+		Clauses(clause.Returning{}).
+		Where("stock + ? >= 0", m.Delta).
+		Updates(map[string]any{"stock": gorm.Expr("stock + ?", m.Delta), "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInsufficientStock
+	}
+	old := p.Stock - m.Delta
+	moves, err := writeMovements(tx, p, old, m)
+	if err != nil {
+		return nil, err
+	}
+	return moves, stockChanged(tx, p, old)
+}
+
+// writeMovements раскладывает m.Delta по складам и пишет движения с нарастающим Balance
+func writeMovements(tx *gorm.DB, p Product, old int, m InventoryMovement) ([]InventoryMovement, error) {
+	splits, err := allocate(tx, p.SellerID, p.ID, m.Delta, m.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]InventoryMovement, 0, len(splits))
+	balance := old
+	for _, s := range splits {
+		mv := m
+		mv.WarehouseID = &s.WarehouseID
+		mv.Delta = s.Qty
+		balance += s.Qty
+		mv.Balance = balance
+		if err := tx.Create(&mv).Error; err != nil {
+			return nil, err
+		}
+		moves = append(moves, mv)
+	}
+	return moves, nil
+}
+
+// recordStockChange — движение для правки товара, которая пишет ревизию (форма, импорт, API...)
+func recordStockChange(tx *gorm.DB, before *Product, after Product, rev ProductRevision) error {
+	old := 0
+	if before != nil {
+		old = before.Stock
+	}
+	if after.Stock == old {
+		return nil
+	}
+	_, err := writeMovements(tx, after, old, InventoryMovement{
+		ProductID: after.ID,
+		Delta:     after.Stock - old,
+		Reason:    revisionReasons[rev.Action],
+		ActorID:   rev.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	return stockChanged(tx, after, old)
+}
+
+// stockChanged ставит в очередь уведомления об изменении остатка p (old — остаток до):
+// продавцу — когда остаток опустился до порога LowStock, подписчикам — когда товар снова появился.
+func stockChanged(tx *gorm.DB, p Product, old int) error {
+	if p.LowStock > 0 && old > p.LowStock && p.Stock <= p.LowStock {
+		err := Notify(tx, Notification{
+			UserID:  p.SellerID,
+			Kind:    NotifyLowStock,
+			Subject: fmt.Sprintf("Low stock: %s (%d left)", p.Title, p.Stock),
+			Body:    fmt.Sprintf("Stock of %q dropped to %d, your alert threshold is %d.", p.Title, p.Stock, p.LowStock),
+			Link:    fmt.Sprintf("/seller/products/%d/inventory", p.ID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// снятые с продажи товары купить всё равно нельзя — подписчики дождутся следующего поступления
+	if old > 0 || p.Stock <= 0 || p.Status != StatusPublished || p.Archived() {
+		return nil
+	}
+	var subs []StockSubscription
+	if err := tx.Where("product_id = ? AND notified_at IS NULL", p.ID).Find(&subs).Error; err != nil {
+		return err
+	}
+	for _, s := range subs {
+		err := Notify(tx, Notification{
+			UserID:  s.UserID,
+			Kind:    NotifyBackInStock,
+			Subject: "Back in stock: " + p.Title,
+			Body:    fmt.Sprintf("%q is available again.", p.Title),
+			Link:    fmt.Sprintf("/product/%d", p.ID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	return tx.Model(&StockSubscription{}).
+		Where("product_id = ? AND notified_at IS NULL", p.ID).
+		Update("notified_at", time.Now()).Error
+}
+
+// AdjustStock — ручная корректировка остатка на складе warehouseID с причиной: движение и ревизия товара
+func AdjustStock(tx *gorm.DB, p *Product, warehouseID uint, delta int, reason MovementReason, actorID uint, note string) error {
+	before := *p
+	m := InventoryMovement{ProductID: p.ID, WarehouseID: &warehouseID, Delta: delta, Reason: reason, ActorID: actorID, Note: note}
+	moves, err := MoveStock(tx, m)
+	if err != nil {
+		return err
+	}
+	p.Stock = moves[len(moves)-1].Balance
+	p.Version++
+	return writeRevision(tx, &before, *p, ProductRevision{UserID: actorID, Action: RevisionAdjust})
+}
+
+// OpenBalances пишет движение initial товарам с остатком, у которых журнала ещё нет.
+// Повторный вызов ничего не делает.
+func OpenBalances(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO inventory_movements (created_at, product_id, delta, balance, reason, actor_id)
+		SELECT NOW(), p.id, p.stock, p.stock, ?, 0
+		FROM products p
+		WHERE p.stock <> 0
+		  AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.product_id = p.id)`, MoveInitial).Error
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"marketplace/internal/money"
+)
+
+// LedgerAccount — счёт в книге площадки. У счетов продавца в проводке заполнен SellerID.
+type LedgerAccount string
+
+const (
+	AccountCash       LedgerAccount = "cash"             // деньги покупателей на счёте площадки (актив)
+	AccountCommission LedgerAccount = "commission"       // комиссия площадки (доход)
+	AccountPending    LedgerAccount = "seller_pending"   // долг продавцу, ещё на удержании
+	AccountAvailable  LedgerAccount = "seller_available" // долг продавцу, можно выплатить
+	AccountPayout     LedgerAccount = "payout_clearing"  // выплата отправлена провайдеру, ждём результата
+)
+
+// LedgerKind — хозяйственная операция
+type LedgerKind string
+
+const (
+	LedgerSale         LedgerKind = "sale"          // заказ: выручка продавца на удержание, комиссия площадке
+	LedgerRelease      LedgerKind = "release"       // покупатель получил заказ, удержание по продаже закончилось
+	LedgerRefund       LedgerKind = "refund"        // возврат денег покупателю
+	LedgerPayout       LedgerKind = "payout"        // выплата продавцу отправлена
+	LedgerPayoutPaid   LedgerKind = "payout_paid"   // провайдер подтвердил выплату
+	LedgerPayoutFailed LedgerKind = "payout_failed" // выплата не прошла, деньги вернулись в доступные
+)
+
+// LedgerTransaction — таблица ledger_transactions: операция из проводок с нулевой суммой
+// (двойная запись). Записи не меняются и не удаляются — ошибки исправляются новой операцией.
+type LedgerTransaction struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Kind      LedgerKind `gorm:"type:varchar(16);not null;index"`
+	OrderID   *uint      `gorm:"index"`
+	SellerID  *uint      `gorm:"index"`
+	PayoutID  *uint      `gorm:"index"`
+	// SaleID — к какой продаже относится release или refund; release по продаже бывает один
+	SaleID   *uint `gorm:"index;uniqueIndex:idx_ledger_release,where:kind = 'release'"`
+	Memo     string
+	Postings []LedgerPosting `gorm:"foreignKey:TransactionID"`
+}
+
+// LedgerPosting — таблица ledger_postings: проводка. Amount > 0 — дебет, < 0 — кредит.
+// Остаток счёта продавца (пассив) — минус сумма его проводок.
+type LedgerPosting struct {
+	ID            uint           `gorm:"primaryKey"`
+	TransactionID uint           `gorm:"index;not null"`
+	Account       LedgerAccount  `gorm:"type:varchar(24);not null;index:idx_ledger_account,priority:2"`
+	SellerID      *uint          `gorm:"index:idx_ledger_account,priority:1"`
+	Amount        int64          `gorm:"not null"`
+	Currency      money.Currency `gorm:"type:varchar(3);not null"`
+}
+
+// CommissionRule — таблица commission_rules: ставка комиссии в базисных пунктах (1% = 100).
+// Действует самое узкое правило: продавец+категория, продавец, категория, общее.
+type CommissionRule struct {
+	Base
+	SellerID   *uint `gorm:"index"`
+	CategoryID *uint `gorm:"index"`
+	RateBP     int   `gorm:"not null"`
+}
+
+// Percent — ставка для показа: "5", "7.5"
+func (r CommissionRule) Percent() string {
+	return strconv.FormatFloat(float64(r.RateBP)/100, 'f', -1, 64)
+}
+
+// PayoutStatus — pending → paid | failed
+type PayoutStatus string
+
+const (
+	PayoutPending PayoutStatus = "pending"
+	PayoutPaid    PayoutStatus = "paid"
+	PayoutFailed  PayoutStatus = "failed"
+)
+
+// Payout — таблица payouts: перевод доступного остатка продавцу через провайдера выплат
+type Payout struct {
+	Base
+	SellerID  uint           `gorm:"index;not null"`
+	Amount    int64          `gorm:"not null"`
+	Currency  money.Currency `gorm:"type:varchar(3);not null"`
+	Status    PayoutStatus   `gorm:"type:varchar(16);not null;index"`
+	Provider  string         `gorm:"type:varchar(32);not null"`
+	Reference string         // номер перевода у провайдера
+	Error     string
+	PaidAt    *time.Time
+}
+
+// Money — сумма выплаты
+func (p Payout) Money() money.Money { return money.New(p.Amount, p.Currency) }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationKind — о чём уведомление
+type NotificationKind string
+
+const (
+	NotifyLowStock     NotificationKind = "low_stock"     // продавцу: остаток упал до порога
+	NotifyBackInStock  NotificationKind = "back_in_stock" // покупателю: товар снова в наличии
+	NotifyVerification NotificationKind = "verification"  // продавцу: решение по анкете
+	NotifyShipped      NotificationKind = "shipped"       // покупателю: продавец отправил заказ
+	NotifyDelivered    NotificationKind = "delivered"     // продавцу: покупатель получил заказ
+	NotifyDispute      NotificationKind = "dispute"       // участникам спора: новое сообщение или решение
+	NotifyReview       NotificationKind = "review"        // продавцу: новый отзыв; покупателю: ответ или модерация
+)
+
+// Notification — таблица notifications: очередь исходящих уведомлений (outbox).
+// Пишется в той же транзакции, что и событие; отправляет фоновый notify.Dispatcher.
+type Notification struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UserID    uint             `gorm:"index;not null"`
+	Kind      NotificationKind `gorm:"type:varchar(32);not null"`
+	Subject   string           `gorm:"not null"`
+	Body      string           `gorm:"type:text"`
+	Link      string           // путь на сайте, напр. "/product/12"
+	Attempts  int              `gorm:"not null;default:0"`
+	Error     string
+	SentAt    *time.Time `gorm:"index"`
+}
+
+// Notify ставит уведомление в очередь
+func Notify(tx *gorm.DB, n Notification) error {
+	return tx.Create(&n).Error
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"marketplace/internal/money"
+)
+
+// OrderStatus — статус заказа: pending → paid | payment_failed, дальше по отправлениям
+type OrderStatus string
+
+const (
+	// OrderPending — заказ оформлен, товар зарезервирован, деньги ещё не списаны
+	OrderPending OrderStatus = "pending"
+	// OrderPaymentFailed — шлюз отказал в оплате, резерв снят
+	OrderPaymentFailed OrderStatus = "payment_failed"
+	// OrderPaid — оплачен: продажа в книге, продавцы собирают свои части
+	OrderPaid OrderStatus = "paid"
+	// OrderShipped — все продавцы отправили свои части заказа
+	OrderShipped OrderStatus = "shipped"
+	// OrderDelivered — все части получены (отменённые не в счёт)
+	OrderDelivered OrderStatus = "delivered"
+	// OrderCancelled — все части отменены до отправки, деньги вернули
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// SaleStatuses — статусы заказов, которые считаются продажей (выручка, аналитика)
+var SaleStatuses = []OrderStatus{OrderPaid, OrderShipped, OrderDelivered}
+
+// Order — таблица orders. Все суммы заказа — в валюте расчётов Currency.
+type Order struct {
+	Base
+	BuyerID     uint           `gorm:"index;not null"`
+	Status      OrderStatus    `gorm:"type:varchar(16);not null;index"`
+	Currency    money.Currency `gorm:"type:varchar(3);not null"`
+	TotalAmount int64          `gorm:"not null"`
+
+	// оплата через payments.Provider
+	PaymentProvider string `gorm:"type:varchar(32)"`
+	PaymentRef      string // номер платежа у шлюза
+	PaidAt          *time.Time
+
+	Items     []OrderItem
+	Shipments []Shipment // по одному на продавца
+}
+
+// Total — сумма заказа
+func (o Order) Total() money.Money { return money.New(o.TotalAmount, o.Currency) }
+
+// OrderItem — таблица order_items: позиция заказа с ценой на момент покупки
+type OrderItem struct {
+	Base
+	OrderID    uint   `gorm:"index;not null"`
+	ProductID  uint   `gorm:"index;not null"`
+	SellerID   uint   `gorm:"index;not null"`
+	Title      string `gorm:"not null"`
+	Qty        int    `gorm:"not null"`
+	UnitAmount int64  `gorm:"not null"` // цена за штуку в валюте заказа
+
+	// цена продавца в его валюте и курс пересчёта в валюту заказа
+	ListAmount   int64          `gorm:"not null"`
+	ListCurrency money.Currency `gorm:"type:varchar(3);not null"`
+	Rate         float64        `gorm:"not null;default:1"`
+
+	// склад отгрузки; nil — позицию собирают с нескольких складов (см. движения заказа)
+	WarehouseID *uint `gorm:"index"`
+}
+
+// Subtotal — сумма позиции в валюте заказа
+func (it OrderItem) Subtotal(cur money.Currency) money.Money {
+	return money.New(it.UnitAmount*int64(it.Qty), cur)
+}
@@ -1,12 +1,128 @@
 package models
 
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/money"
+)
+
+// ListingStatus — жизненный цикл объявления: draft → scheduled → published → unpublished
+type ListingStatus string
+
+const (
+	StatusDraft       ListingStatus = "draft"       // черновик, виден только продавцу
+	StatusScheduled   ListingStatus = "scheduled"   // опубликуется планировщиком в PublishAt
+	StatusPublished   ListingStatus = "published"   // на витрине
+	StatusUnpublished ListingStatus = "unpublished" // снят с витрины продавцом
+)
+
+// Valid — известный статус
+func (s ListingStatus) Valid() bool {
+	switch s {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusUnpublished:
+		return true
+	}
+	return false
+}
+
 // Product — таблица products
 type Product struct {
 	Base
-	SellerID    uint   `gorm:"index;not null"`
+	SellerID    uint   `gorm:"index;not null;uniqueIndex:idx_products_seller_sku,where:sku <> '';uniqueIndex:idx_products_seller_external,where:external_id <> ''"`
+	SKU         string `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_products_seller_sku,where:sku <> ''"`              // артикул продавца, ключ импорта
+	ExternalID  string `gorm:"type:varchar(80);not null;default:'';uniqueIndex:idx_products_seller_external,where:external_id <> ''"` // Ид товара в 1С (CommerceML)
 	Title       string `gorm:"not null"`
 	Description string `gorm:"type:text"`
-	PriceCents  int    `gorm:"not null"`
+	PriceCents  int    `gorm:"not null"` // в минимальных единицах Currency
 	Stock       int    `gorm:"not null;default:0"`
+	LowStock    int    `gorm:"not null;default:0"` // уведомить продавца, когда остаток опустится до этого числа; 0 — не следить
 	ImagePath   string // относительный путь, напр. "/uploads/abc123.jpg"
+	CategoryID  *uint  `gorm:"index"`
+
+	// Currency — валюта, в которой продавец указал цену; default USD — старые цены были в долларах
+	Currency money.Currency `gorm:"type:varchar(3);not null;default:'USD'"`
+
+	// default published — чтобы уже существующие товары остались на витрине после миграции
+	Status    ListingStatus `gorm:"type:varchar(16);not null;default:'published';index"`
+	PublishAt *time.Time    `gorm:"index"` // когда опубликовать (для scheduled)
+
+	// рейтинг по опубликованным отзывам, для сортировки каталога; пересчитывает RecountRating
+	RatingAvg   float64 `gorm:"not null;default:0;index"`
+	RatingCount int     `gorm:"not null;default:0"`
+
+	// Version растёт на каждом UpdateProduct — защита от затирания параллельных правок
+	Version int `gorm:"not null;default:1"`
+
+	ArchivedAt *time.Time     `gorm:"index"` // снят с витрины продавцом, можно вернуть из архива
+	DeletedAt  gorm.DeletedAt `gorm:"index"` // soft delete: строка остаётся для истории заказов
+}
+
+// Price — цена товара в его валюте
+func (p Product) Price() money.Money {
+	return money.New(int64(p.PriceCents), p.Currency)
+}
+
+// Archived — товар в архиве или удалён (показывается во вкладке «Архив»)
+func (p Product) Archived() bool {
+	return p.ArchivedAt != nil || p.DeletedAt.Valid
+}
+
+// ActiveProducts — scope для кабинета продавца: не архивные и не удалённые товары в любом статусе
+func ActiveProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.archived_at IS NULL")
+}
+
+// ListedProducts — scope для витрины и корзины: активные и опубликованные товары
+func ListedProducts(db *gorm.DB) *gorm.DB {
+	return ActiveProducts(db).Where("products.status = ?", StatusPublished)
+}
+
+// PublishDue публикует scheduled-товары, у которых наступил PublishAt. Возвращает число опубликованных.
+func PublishDue(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&Product{}).
+		Where("status = ? AND publish_at <= ?", StatusScheduled, now).
+		Update("status", StatusPublished)
+	return res.RowsAffected, res.Error
+}
+
+// ArchivedProducts — scope для вкладки «Архив»: архивные и удалённые товары (нужен Unscoped)
+func ArchivedProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.archived_at IS NOT NULL OR products.deleted_at IS NOT NULL")
+}
+
+// ErrVersionConflict — товар успели изменить после того, как его прочитали
+var ErrVersionConflict = errors.New("product was modified by someone else")
+
+// UpdateProduct сохраняет редактируемые поля p, только если в БД всё ещё версия version
+// (UPDATE ... WHERE id = ? AND version = ?). Иначе ErrVersionConflict.
+// При успехе p.Version = version+1. Все правки товара должны идти через эту функцию.
+func UpdateProduct(tx *gorm.DB, p *Product, version int) error {
+	res := tx.Model(&Product{}).
+		Where("id = ? AND version = ?", p.ID, version).
+		Updates(map[string]any{
+			"sku":         p.SKU,
+			"external_id": p.ExternalID,
+			"title":       p.Title,
+			"description": p.Description,
+			"price_cents": p.PriceCents,
+			"currency":    p.Currency,
+			"stock":       p.Stock,
+			"image_path":  p.ImagePath,
+			"status":      p.Status,
+			"publish_at":  p.PublishAt,
+			"category_id": p.CategoryID,
+			"low_stock":   p.LowStock,
+			"version":     version + 1,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	p.Version = version + 1
+	return nil
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ProductViewStat — таблица product_view_stats: дневной счётчик просмотров карточки товара.
+// Одна строка на товар в день, чтобы аналитика не считала сырые события.
+type ProductViewStat struct {
+	ProductID uint      `gorm:"primaryKey"`
+	Day       time.Time `gorm:"primaryKey;type:date"`
+	Views     int64     `gorm:"not null;default:0"`
+}
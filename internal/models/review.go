@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReviewStatus — published | hidden (скрыт модератором)
+type ReviewStatus string
+
+const (
+	ReviewPublished ReviewStatus = "published"
+	ReviewHidden    ReviewStatus = "hidden"
+)
+
+// Review — таблица reviews: отзыв покупателя о товаре из полученного заказа, один на позицию.
+// В рейтинг товара (Product.RatingAvg, RatingCount) идут только опубликованные.
+type Review struct {
+	Base
+	ProductID   uint         `gorm:"index;not null"`
+	OrderItemID uint         `gorm:"uniqueIndex;not null"`
+	BuyerID     uint         `gorm:"index;not null"`
+	SellerID    uint         `gorm:"index;not null"`
+	Rating      int          `gorm:"not null"` // 1..5
+	Body        string       `gorm:"type:text"`
+	Status      ReviewStatus `gorm:"type:varchar(16);not null;default:'published';index"`
+
+	// модерация
+	HiddenReason string
+	ModeratedBy  *uint
+	ModeratedAt  *time.Time
+
+	// ответ продавца
+	Reply     string `gorm:"type:text"`
+	RepliedAt *time.Time
+
+	Photos []ReviewPhoto
+}
+
+// Stars — рейтинг звёздочками: "★★★★☆"
+func (r Review) Stars() string {
+	return strings.Repeat("★", r.Rating) + strings.Repeat("☆", 5-r.Rating)
+}
+
+// ReviewPhoto — таблица review_photos: фото к отзыву (путь в uploads)
+type ReviewPhoto struct {
+	ID       uint   `gorm:"primaryKey"`
+	ReviewID uint   `gorm:"index;not null"`
+	Path     string `gorm:"not null"`
+}
+
+// RecountRating пересчитывает рейтинг товара по опубликованным отзывам. Версия товара не меняется —
+// это не правка продавца.
+func RecountRating(tx *gorm.DB, productID uint) error {
+	return tx.Exec(`
+		UPDATE products SET
+			rating_count = (SELECT COUNT(*) FROM reviews r WHERE r.product_id = products.id AND r.status = ?),
+			rating_avg = COALESCE((SELECT ROUND(AVG(r.rating), 2) FROM reviews r WHERE r.product_id = products.id AND r.status = ?), 0)
+		WHERE id = ?`, ReviewPublished, ReviewPublished, productID).Error
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/money"
+)
+
+// RevisionAction — что произошло с товаром
+type RevisionAction string
+
+const (
+	RevisionCreate   RevisionAction = "create"
+	RevisionUpdate   RevisionAction = "update"
+	RevisionRollback RevisionAction = "rollback"
+	RevisionImport   RevisionAction = "import"
+	RevisionExchange RevisionAction = "exchange" // обмен с 1С
+	RevisionAPI      RevisionAction = "api"      // ключ API продавца
+	RevisionBulk     RevisionAction = "bulk"     // массовое действие в списке товаров
+	RevisionUndo     RevisionAction = "undo"     // отмена массового действия
+	RevisionAdjust   RevisionAction = "adjust"   // корректировка остатка (AdjustStock)
+)
+
+// ProductSnapshot — редактируемые поля товара на момент ревизии
+type ProductSnapshot struct {
+	SKU         string         `json:"sku,omitempty"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	PriceCents  int            `json:"price_cents"`
+	Currency    money.Currency `json:"currency,omitempty"`
+	Stock       int            `json:"stock"`
+	ImagePath   string         `json:"image_path"`
+	Status      ListingStatus  `json:"status"`
+	PublishAt   *time.Time     `json:"publish_at,omitempty"`
+	CategoryID  *uint          `json:"category_id,omitempty"`
+	LowStock    int            `json:"low_stock,omitempty"`
+}
+
+// FieldChange — одно изменённое поле (значения уже отформатированы для показа)
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ProductRevision — таблица product_revisions: кто, когда и что поменял в товаре
+type ProductRevision struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	ProductID  uint            `gorm:"index;not null"`
+	UserID     uint            `gorm:"not null"`
+	Action     RevisionAction  `gorm:"type:varchar(16);not null"`
+	RollbackTo *uint           // для rollback — к какой ревизии откатили
+	Changes    []FieldChange   `gorm:"type:jsonb;serializer:json"`
+	Snapshot   ProductSnapshot `gorm:"type:jsonb;serializer:json"` // состояние ПОСЛЕ изменения
+}
+
+// SnapshotOf снимает редактируемые поля товара
+func SnapshotOf(p Product) ProductSnapshot {
+	return ProductSnapshot{
+		SKU:         p.SKU,
+		Title:       p.Title,
+		Description: p.Description,
+		PriceCents:  p.PriceCents,
+		Currency:    p.Currency,
+		Stock:       p.Stock,
+		ImagePath:   p.ImagePath,
+		Status:      p.Status,
+		PublishAt:   p.PublishAt,
+		CategoryID:  p.CategoryID,
+		LowStock:    p.LowStock,
+	}
+}
+
+// ApplyTo переносит поля снимка в товар (для отката). Остаток не трогает: его ведёт журнал
+// движения, и старый остаток из снимка вернул бы уже проданные штуки.
+func (s ProductSnapshot) ApplyTo(p *Product) {
+	p.SKU = s.SKU
+	p.Title = s.Title
+	p.Description = s.Description
+	p.PriceCents = s.PriceCents
+	if s.Currency != "" { // ревизии до мультивалютности валюту не хранили
+		p.Currency = s.Currency
+	}
+	p.ImagePath = s.ImagePath
+	p.Status = s.Status
+	p.PublishAt = s.PublishAt
+	p.CategoryID = s.CategoryID
+	p.LowStock = s.LowStock
+}
+
+func formatID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%d", *id)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// Diff — какие поля отличаются между a (до) и b (после)
+func (a ProductSnapshot) Diff(b ProductSnapshot) []FieldChange {
+	var out []FieldChange
+	add := func(field, old, new string) {
+		if old != new {
+			out = append(out, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("sku", a.SKU, b.SKU)
+	add("title", a.Title, b.Title)
+	add("description", a.Description, b.Description)
+	add("price", money.New(int64(a.PriceCents), a.Currency).Decimal(), money.New(int64(b.PriceCents), b.Currency).Decimal())
+	add("currency", string(a.Currency), string(b.Currency))
+	add("stock", fmt.Sprint(a.Stock), fmt.Sprint(b.Stock))
+	add("image", a.ImagePath, b.ImagePath)
+	add("status", string(a.Status), string(b.Status))
+	add("publish_at", formatTime(a.PublishAt), formatTime(b.PublishAt))
+	add("category", formatID(a.CategoryID), formatID(b.CategoryID))
+	add("low_stock", fmt.Sprint(a.LowStock), fmt.Sprint(b.LowStock))
+	return out
+}
+
+// RecordRevision пишет ревизию товара after. before == nil — товар только что создан.
+// В rev заполняются UserID, Action и (для отката) RollbackTo, остальное считается здесь.
+// Если ничего не поменялось, ревизия не пишется. Изменение остатка попадает в журнал движения.
+func RecordRevision(tx *gorm.DB, before *Product, after Product, rev ProductRevision) error {
+	if err := writeRevision(tx, before, after, rev); err != nil {
+		return err
+	}
+	return recordStockChange(tx, before, after, rev)
+}
+
+func writeRevision(tx *gorm.DB, before *Product, after Product, rev ProductRevision) error {
+	var prev ProductSnapshot
+	if before != nil {
+		prev = SnapshotOf(*before)
+	}
+	snap := SnapshotOf(after)
+	changes := prev.Diff(snap)
+	if before != nil && len(changes) == 0 {
+		return nil
+	}
+	rev.ProductID = after.ID
+	rev.Changes = changes
+	rev.Snapshot = snap
+	return tx.Create(&rev).Error
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+// Откат возвращает поля товара, но не остаток: его ведёт журнал движения
+func TestSnapshotApplyToKeepsStock(t *testing.T) {
+	rev := SnapshotOf(Product{Title: "Old", PriceCents: 100, Currency: "USD", Stock: 10})
+	p := Product{Title: "New", PriceCents: 200, Currency: "USD", Stock: 7} // с ревизии продали 3
+	before := SnapshotOf(p)
+	rev.ApplyTo(&p)
+	if p.Title != "Old" || p.PriceCents != 100 {
+		t.Errorf("fields not restored: %+v", p)
+	}
+	if p.Stock != 7 {
+		t.Errorf("stock = %d, want 7", p.Stock)
+	}
+	for _, c := range before.Diff(SnapshotOf(p)) {
+		if c.Field == "stock" {
+			t.Errorf("rollback diff has stock: %+v", c)
+		}
+	}
+}
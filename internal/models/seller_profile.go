@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SellerProfile — таблица seller_profiles: публичная витрина продавца /shop/:slug
+type SellerProfile struct {
+	Base
+	SellerID    uint   `gorm:"uniqueIndex;not null"`
+	ShopName    string `gorm:"not null"`
+	Slug        string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	LogoPath    string // "/uploads/…", как Product.ImagePath
+	BannerPath  string
+	Description string `gorm:"type:text"`
+	Policies    string `gorm:"type:text"` // доставка, возврат, гарантия — как написал продавец
+
+	// отпуск: товары остаются на витрине, но купить их нельзя.
+	// VacationUntil — когда планировщик сам вернёт магазин (EndVacations); nil — пока продавец не вернёт сам
+	VacationFrom  *time.Time
+	VacationUntil *time.Time `gorm:"index"`
+	VacationNote  string
+
+	Rating *SellerRating `gorm:"foreignKey:SellerID;references:SellerID"` // nil — ещё не считали
+}
+
+// OnVacation — магазин на паузе
+func (p SellerProfile) OnVacation() bool {
+	return p.VacationFrom != nil
+}
+
+// SellerAway — профиль продавца, если его магазин на паузе; иначе nil
+func SellerAway(db *gorm.DB, sellerID uint) (*SellerProfile, error) {
+	var p SellerProfile
+	err := db.Where("seller_id = ? AND vacation_from IS NOT NULL", sellerID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// NotOnVacation — scope для фидов: товары продавцов, чей магазин не на паузе
+func NotOnVacation(db *gorm.DB) *gorm.DB {
+	return db.Where("products.seller_id NOT IN (SELECT seller_id FROM seller_profiles WHERE vacation_from IS NOT NULL)")
+}
+
+// EndVacations возвращает из отпуска магазины, у которых наступил VacationUntil. Возвращает их число.
+func EndVacations(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&SellerProfile{}).
+		Where("vacation_from IS NOT NULL AND vacation_until <= ?", now).
+		Updates(map[string]any{"vacation_from": nil, "vacation_until": nil, "vacation_note": ""})
+	return res.RowsAffected, res.Error
+}
+
+// SlugPattern — допустимый адрес витрины: латиница в нижнем регистре, цифры и дефисы
+var SlugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{1,62}[a-z0-9])$`)
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify предлагает адрес витрины по названию; если латиницы в названии нет — shop-<id>
+func Slugify(name string, sellerID uint) string {
+	s := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(s) > 64 {
+		s = strings.TrimRight(s[:64], "-")
+	}
+	if !SlugPattern.MatchString(s) {
+		return fmt.Sprintf("shop-%d", sellerID)
+	}
+	return s
+}
+
+// ShopsOf — профили продавцов по id (у кого профиля нет, того в карте нет)
+func ShopsOf(db *gorm.DB, sellerIDs []uint) (map[uint]*SellerProfile, error) {
+	out := map[uint]*SellerProfile{}
+	if len(sellerIDs) == 0 {
+		return out, nil
+	}
+	var profiles []SellerProfile
+	if err := db.Preload("Rating").Where("seller_id IN ?", sellerIDs).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		out[profiles[i].SellerID] = &profiles[i]
+	}
+	return out, nil
+}
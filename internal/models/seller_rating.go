@@ -0,0 +1,52 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// SellerFeedback — таблица seller_feedbacks: оценка покупателем продавца по полученной части заказа, одна на отправление.
+// Оценки 1..5.
+type SellerFeedback struct {
+	Base
+	ShipmentID    uint   `gorm:"uniqueIndex;not null"`
+	OrderID       uint   `gorm:"index;not null"`
+	SellerID      uint   `gorm:"index;not null"`
+	BuyerID       uint   `gorm:"index;not null"`
+	Shipping      int    `gorm:"not null"` // скорость отправки
+	Communication int    `gorm:"not null"` // общение
+	Accuracy      int    `gorm:"not null"` // товар соответствует описанию
+	Comment       string `gorm:"type:text"`
+}
+
+// Score — средняя из трёх оценок
+func (f SellerFeedback) Score() float64 {
+	return float64(f.Shipping+f.Communication+f.Accuracy) / 3
+}
+
+// SellerRating — таблица seller_ratings: репутация продавца за последний период.
+// Считается фоновой задачей (reviews.RecountSellers) целиком заново; руками не правится.
+type SellerRating struct {
+	SellerID uint `gorm:"primaryKey;autoIncrement:false"`
+
+	// по оценкам покупателей
+	FeedbackCount int     `gorm:"not null;default:0"`
+	Score         float64 `gorm:"not null;default:0"` // среднее по трём оценкам
+	Shipping      float64 `gorm:"not null;default:0"`
+	Communication float64 `gorm:"not null;default:0"`
+	Accuracy      float64 `gorm:"not null;default:0"`
+
+	// по заказам
+	Shipments int     `gorm:"not null;default:0"` // частей заказов за период
+	Due       int     `gorm:"not null;default:0"` // из них пора было отправить
+	OnTime    float64 `gorm:"not null;default:0"` // доля отправленных в срок среди Due
+	Cancelled float64 `gorm:"not null;default:0"` // доля возвращённых деньгами до отправки
+
+	UpdatedAt time.Time
+}
+
+// OnTimePercent — доля отправленных в срок, %
+func (r SellerRating) OnTimePercent() int { return int(math.Round(r.OnTime * 100)) }
+
+// CancelledPercent — доля отменённых, %
+func (r SellerRating) CancelledPercent() int { return int(math.Round(r.Cancelled * 100)) }
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LegalForm — форма ведения деятельности продавца
+type LegalForm string
+
+const (
+	LegalIndividual LegalForm = "individual" // физлицо / самозанятый
+	LegalIP         LegalForm = "ip"         // ИП
+	LegalOOO        LegalForm = "ooo"        // ООО
+)
+
+// LegalForms — в порядке показа в форме
+var LegalForms = []LegalForm{LegalIndividual, LegalIP, LegalOOO}
+
+// Valid — известная форма
+func (f LegalForm) Valid() bool {
+	switch f {
+	case LegalIndividual, LegalIP, LegalOOO:
+		return true
+	}
+	return false
+}
+
+// VerificationStatus — draft → pending → approved | rejected (→ снова pending после исправления)
+type VerificationStatus string
+
+const (
+	VerificationDraft    VerificationStatus = "draft"    // продавец заполняет анкету
+	VerificationPending  VerificationStatus = "pending"  // в очереди на проверку
+	VerificationApproved VerificationStatus = "approved" // можно публиковать товары и получать выплаты
+	VerificationRejected VerificationStatus = "rejected" // вернули на доработку с причиной
+)
+
+// SellerVerification — таблица seller_verifications: анкета продавца и решение модератора.
+// Продавцы, заведённые до онбординга, один раз получили approved без реквизитов (OpenVerifications).
+type SellerVerification struct {
+	Base
+	SellerID  uint      `gorm:"uniqueIndex;not null"`
+	LegalForm LegalForm `gorm:"type:varchar(16);not null;default:''"`
+	LegalName string    // ФИО или название организации
+	INN       string    `gorm:"type:varchar(12)"`
+	OGRN      string    `gorm:"type:varchar(15)"` // ОГРН / ОГРНИП, для физлица пусто
+
+	// банковские реквизиты для выплат
+	BankName    string
+	BIK         string `gorm:"type:varchar(9)"`
+	Account     string `gorm:"type:varchar(20)"` // расчётный счёт
+	CorrAccount string `gorm:"type:varchar(20)"`
+
+	Status       VerificationStatus `gorm:"type:varchar(16);not null;default:'draft';index"`
+	SubmittedAt  *time.Time
+	ReviewerID   *uint
+	ReviewedAt   *time.Time
+	RejectReason string `gorm:"type:text"`
+
+	Documents []VerificationDocument `gorm:"foreignKey:VerificationID"`
+}
+
+// Editable — анкету можно править (до отправки или после отказа)
+func (v SellerVerification) Editable() bool {
+	return v.Status == VerificationDraft || v.Status == VerificationRejected
+}
+
+// HasBank — реквизиты для выплат заполнены; без них остаток ждёт на счёте продавца
+func (v SellerVerification) HasBank() bool {
+	return v.BIK != "" && v.Account != ""
+}
+
+// BankEditable — одобренному продавцу без реквизитов (заведён до онбординга) их можно дописать
+func (v SellerVerification) BankEditable() bool {
+	return v.Status == VerificationApproved && !v.HasBank()
+}
+
+// VerificationDocument — таблица verification_documents: скан документа продавца.
+// Файл лежит в закрытом каталоге (не /uploads) и отдаётся только модератору.
+type VerificationDocument struct {
+	ID             uint `gorm:"primaryKey"`
+	CreatedAt      time.Time
+	VerificationID uint   `gorm:"index;not null"`
+	FileName       string `gorm:"not null"` // как назывался у продавца
+	Path           string `gorm:"not null"` // имя файла в каталоге документов
+	Size           int64
+}
+
+// ErrUnverified — продавец ещё не прошёл проверку
+var ErrUnverified = errors.New("seller is not verified yet")
+
+// SellerVerified — продавцу можно публиковать товары и получать выплаты
+func SellerVerified(db *gorm.DB, sellerID uint) (bool, error) {
+	var n int64
+	err := db.Model(&SellerVerification{}).
+		Where("seller_id = ? AND status = ?", sellerID, VerificationApproved).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// OpenVerifications считает проверенными продавцов и админов, заведённых до онбординга.
+// Срабатывает один раз — пока таблица анкет пуста, то есть при первом запуске с онбордингом.
+// Дальше продавец без анкеты проверенным не становится: анкету-черновик заводят
+// и форма онбординга, и `mpctl users role`.
+func OpenVerifications(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&SellerVerification{}).Unscoped().Limit(1).Count(&n).Error; err != nil || n > 0 {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO seller_verifications (created_at, updated_at, seller_id, status, reviewed_at)
+			SELECT NOW(), NOW(), u.id, ?, NOW()
+			FROM users u
+			WHERE u.role IN ?`,
+			VerificationApproved, []Role{RoleSeller, RoleAdmin}).Error
+	})
+}
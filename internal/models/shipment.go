@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShipmentStatus — awaiting → shipped → delivered; awaiting → cancelled
+type ShipmentStatus string
+
+const (
+	ShipmentAwaiting  ShipmentStatus = "awaiting"  // оплачено, продавец собирает
+	ShipmentShipped   ShipmentStatus = "shipped"   // передано в доставку
+	ShipmentDelivered ShipmentStatus = "delivered" // покупатель подтвердил получение (или истёк срок)
+	ShipmentCancelled ShipmentStatus = "cancelled" // до отправки покупателю вернули все деньги
+)
+
+// Shipment — таблица shipments: часть заказа одного продавца. Выручка по ней стоит на удержании,
+// пока покупатель не подтвердит получение (или не выйдет срок автоподтверждения после отправки)
+// и ещё ledger.DefaultHold после этого.
+type Shipment struct {
+	Base
+	OrderID        uint           `gorm:"not null;uniqueIndex:idx_shipment,priority:1"`
+	SellerID       uint           `gorm:"not null;uniqueIndex:idx_shipment,priority:2;index"`
+	Status         ShipmentStatus `gorm:"type:varchar(16);not null;index"`
+	Carrier        string
+	TrackingNumber string
+	ShippedAt      *time.Time `gorm:"index"`
+	DeliveredAt    *time.Time
+	AutoConfirmed  bool `gorm:"not null;default:false"` // получение подтвердил не покупатель, а срок
+
+	// спор по заказу: пока он открыт, выручка не переходит в доступные к выплате
+	FrozenAt     *time.Time
+	FreezeReason string
+}
+
+// Frozen — деньги по отправлению заморожены спором
+func (s Shipment) Frozen() bool { return s.FrozenAt != nil }
+
+// OpenShipments заводит отправления заказам, оформленным до их появления.
+// Заказы без операции sale в книге (до комиссий) считаются доставленными — удерживать по ним нечего.
+// Неоплаченные заказы отправлений не получают: их заводит оплата.
+func OpenShipments(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO shipments (created_at, updated_at, order_id, seller_id, status, delivered_at, auto_confirmed)
+			SELECT o.created_at, NOW(), s.order_id, s.seller_id,
+			       CASE WHEN t.id IS NULL THEN ? ELSE ? END,
+			       CASE WHEN t.id IS NULL THEN o.created_at END,
+			       FALSE
+			FROM (SELECT DISTINCT order_id, seller_id FROM order_items) s
+			JOIN orders o ON o.id = s.order_id
+			LEFT JOIN ledger_transactions t ON t.kind = ? AND t.order_id = s.order_id AND t.seller_id = s.seller_id
+			WHERE o.status IN ?
+			  AND NOT EXISTS (SELECT 1 FROM shipments x WHERE x.order_id = s.order_id AND x.seller_id = s.seller_id)`,
+			ShipmentDelivered, ShipmentAwaiting, LedgerSale, SaleStatuses).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`
+			UPDATE orders SET status = ?
+			WHERE status = ?
+			  AND NOT EXISTS (SELECT 1 FROM shipments s WHERE s.order_id = orders.id AND s.status <> ?)`,
+			OrderDelivered, OrderPaid, ShipmentDelivered).Error
+	})
+}
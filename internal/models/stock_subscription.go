@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// StockSubscription — таблица stock_subscriptions: покупатель ждёт, когда товар снова появится.
+// Срабатывает один раз; повторная подписка снова сбрасывает NotifiedAt.
+type StockSubscription struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	ProductID  uint `gorm:"not null;uniqueIndex:idx_stock_sub"`
+	UserID     uint `gorm:"not null;uniqueIndex:idx_stock_sub"`
+	NotifiedAt *time.Time
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Warehouse — таблица warehouses: склад продавца. Списание идёт со складов по Priority.
+type Warehouse struct {
+	Base
+	SellerID uint   `gorm:"index;not null"`
+	Name     string `gorm:"not null"`
+	Address  string
+	Priority int `gorm:"not null;default:0"` // меньше — раньше отгружаем и сюда же кладём поступления
+}
+
+// StockLevel — таблица stock_levels: остаток товара на складе.
+// Сумма по складам всегда равна Product.Stock — его меняют только вместе (MoveStock, RecordRevision).
+type StockLevel struct {
+	WarehouseID uint `gorm:"primaryKey"`
+	ProductID   uint `gorm:"primaryKey;index"`
+	Qty         int  `gorm:"not null;default:0"`
+}
+
+// ErrWarehouse — склад не найден у продавца
+var ErrWarehouse = errors.New("unknown warehouse")
+
+// MainWarehouse — склад, который заводится продавцу автоматически
+const MainWarehouse = "Main"
+
+// byPriority — порядок складов при отгрузке
+func byPriority(db *gorm.DB) *gorm.DB {
+	return db.Order("warehouses.priority, warehouses.id")
+}
+
+// DefaultWarehouse — первый по приоритету склад продавца; если складов нет, заводит MainWarehouse
+func DefaultWarehouse(tx *gorm.DB, sellerID uint) (*Warehouse, error) {
+	var w Warehouse
+	err := tx.Scopes(byPriority).Where("seller_id = ?", sellerID).First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w = Warehouse{SellerID: sellerID, Name: MainWarehouse}
+		err = tx.Create(&w).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// addLevel прибавляет qty к остатку на складе; при списании — только если хватает.
+// Поступление держит строку склада FOR SHARE: удаление склада блокирует её FOR UPDATE,
+// и остаток не ляжет на склад, который как раз удаляют.
+func addLevel(tx *gorm.DB, warehouseID, productID uint, qty int) error {
+	if qty >= 0 {
+		var w Warehouse
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id").First(&w, warehouseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWarehouse
+			}
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"qty": gorm.Expr("stock_levels.qty + ?", qty)}),
+		}).Create(&StockLevel{WarehouseID: warehouseID, ProductID: productID, Qty: qty}).Error
+	}
+	res := tx.Model(&StockLevel{}).
+		Where("warehouse_id = ? AND product_id = ? AND qty + ? >= 0", warehouseID, productID, qty).
+		Update("qty", gorm.Expr("qty + ?", qty))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+// split — часть изменения остатка, пришедшаяся на один склад
+type split struct {
+	WarehouseID uint
+	Qty         int
+}
+
+// allocate раскладывает изменение остатка товара по складам и меняет StockLevel.
+// only — конкретный склад (корректировка, перемещение). Иначе поступление идёт на склад по умолчанию,
+// а списание — с первого по приоритету склада, где хватает всего количества; если такого нет —
+// по частям со складов в порядке приоритета.
+func allocate(tx *gorm.DB, sellerID, productID uint, delta int, only *uint) ([]split, error) {
+	if only != nil {
+		var w Warehouse
+		if err := tx.First(&w, "id = ? AND seller_id = ?", *only, sellerID).Error; err != nil {
+			return nil, ErrWarehouse
+		}
+		return []split{{w.ID, delta}}, addLevel(tx, w.ID, productID, delta)
+	}
+	if delta > 0 {
+		w, err := DefaultWarehouse(tx, sellerID)
+		if err != nil {
+			return nil, err
+		}
+		return []split{{w.ID, delta}}, addLevel(tx, w.ID, productID, delta)
+	}
+
+	var levels []StockLevel
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Joins("JOIN warehouses ON warehouses.id = stock_levels.warehouse_id").
+		Where("stock_levels.product_id = ? AND stock_levels.qty > 0", productID).
+		Scopes(byPriority).
+		Find(&levels).Error
+	if err != nil {
+		return nil, err
+	}
+	need := -delta
+	var out []split
+	for _, l := range levels {
+		if l.Qty >= need {
+			out = []split{{l.WarehouseID, -need}}
+			break
+		}
+	}
+	if out == nil {
+		for _, l := range levels {
+			take := min(l.Qty, need)
+			out = append(out, split{l.WarehouseID, -take})
+			if need -= take; need == 0 {
+				break
+			}
+		}
+		if need > 0 {
+			return nil, fmt.Errorf("%w: stock levels are short by %d", ErrInsufficientStock, need)
+		}
+	}
+	for _, s := range out {
+		if err := addLevel(tx, s.WarehouseID, productID, s.Qty); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// TransferStock перемещает qty товара p между складами продавца; общий остаток не меняется
+func TransferStock(tx *gorm.DB, p Product, from, to uint, qty int, actorID uint, note string) error {
+	if qty <= 0 || from == to {
+		return fmt.Errorf("choose two different warehouses and a positive quantity")
+	}
+	var n int64
+	if err := tx.Model(&Warehouse{}).Where("id IN ? AND seller_id = ?", []uint{from, to}, p.SellerID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n != 2 {
+		return ErrWarehouse
+	}
+	if err := addLevel(tx, from, p.ID, -qty); err != nil {
+		return err
+	}
+	if err := addLevel(tx, to, p.ID, qty); err != nil {
+		return err
+	}
+	for _, s := range []split{{from, -qty}, {to, qty}} {
+		err := tx.Create(&InventoryMovement{
+			ProductID:   p.ID,
+			WarehouseID: &s.WarehouseID,
+			Delta:       s.Qty,
+			Balance:     p.Stock,
+			Reason:      MoveTransfer,
+			ActorID:     actorID,
+			Note:        note,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenWarehouses заводит склад MainWarehouse продавцам без складов и кладёт на него остатки товаров,
+// у которых ещё нет StockLevel. Повторный вызов ничего не делает.
+func OpenWarehouses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO warehouses (created_at, updated_at, seller_id, name, priority)
+			SELECT NOW(), NOW(), s.seller_id, ?, 0
+			FROM (SELECT DISTINCT seller_id FROM products WHERE stock > 0) s
+			WHERE NOT EXISTS (SELECT 1 FROM warehouses w WHERE w.seller_id = s.seller_id)`, MainWarehouse).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO stock_levels (warehouse_id, product_id, qty)
+			SELECT (SELECT w.id FROM warehouses w WHERE w.seller_id = p.seller_id ORDER BY w.priority, w.id LIMIT 1), p.id, p.stock
+			FROM products p
+			WHERE p.stock > 0
+			  AND NOT EXISTS (SELECT 1 FROM stock_levels l WHERE l.product_id = p.id)`).Error
+	})
+}
@@ -0,0 +1,266 @@
+// Package money — денежные суммы в минимальных единицах валюты (копейки, центы)
+// и строгий разбор цен, которые вводят продавцы.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency — ISO 4217 код валюты
+type Currency string
+
+const (
+	RUB Currency = "RUB"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+)
+
+// format — как валюта показывается пользователю
+type format struct {
+	symbol   string
+	prefix   bool   // символ перед суммой ($1,299.50) или после (1 299,50 ₽)
+	group    string // разделитель разрядов
+	decimal  string // десятичный разделитель
+	fraction int    // сколько знаков после запятой (2 → суммы в копейках/центах)
+}
+
+var formats = map[Currency]format{
+	RUB: {symbol: "₽", group: " ", decimal: ",", fraction: 2},
+	USD: {symbol: "$", prefix: true, group: ",", decimal: ".", fraction: 2},
+	EUR: {symbol: "€", group: " ", decimal: ",", fraction: 2},
+}
+
+func (c Currency) format() format {
+	if f, ok := formats[c]; ok {
+		return f
+	}
+	return format{symbol: string(c), group: " ", decimal: ".", fraction: 2}
+}
+
+// Known — валюта, для которой есть правила форматирования
+func (c Currency) Known() bool {
+	_, ok := formats[c]
+	return ok
+}
+
+// Symbol — символ валюты ("₽", "$")
+func (c Currency) Symbol() string { return c.format().symbol }
+
+// Money — сумма в минимальных единицах валюты: Money{129950, RUB} = 1 299,50 ₽
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// New — сумма из минимальных единиц
+func New(amount int64, cur Currency) Money {
+	return Money{Amount: amount, Currency: cur}
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Mul — сумма × количество (подытог строки корзины)
+func (m Money) Mul(n int64) Money { return Money{Amount: m.Amount * n, Currency: m.Currency} }
+
+var (
+	ErrEmpty    = errors.New("empty amount")
+	ErrSyntax   = errors.New("invalid amount")
+	ErrFraction = errors.New("too many digits after the decimal separator")
+	ErrRange    = errors.New("amount is too large")
+	// ErrAmbiguous — "1.999" в рублях: непонятно, разряды это или дробная часть
+	ErrAmbiguous = errors.New("ambiguous separator, write the amount without thousands separators")
+)
+
+// ParseError — ошибка разбора с исходной строкой
+type ParseError struct {
+	Input string
+	Err   error
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("%q: %v", e.Input, e.Err) }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// spaceSeparators — пробелы, которыми разделяют разряды ("1 299", неразрывные тоже) и швейцарский апостроф
+const spaceSeparators = " \u00a0\u202f'"
+
+// Parse разбирает сумму в форматах "1299", "1299.5", "1 299,50", "1,299.50", "1.299,50".
+// Если разделитель один и после него ровно три цифры ("1,299"), решают правила валюты:
+// её десятичный разделитель — дробная часть ("1.999" в долларах — лишние знаки), её разделитель
+// разрядов — разряды ("1,299" в долларах), любой другой — ErrAmbiguous, а не догадка.
+// Лишние знаки после запятой, буквы и кривая группировка — ошибка, а не молчаливое округление.
+func Parse(s string, cur Currency) (Money, error) {
+	in := s
+	fail := func(err error) (Money, error) { return Money{}, &ParseError{Input: in, Err: err} }
+
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = strings.TrimSpace(s[1:])
+	}
+	if s == "" {
+		return fail(ErrEmpty)
+	}
+
+	// какой из '.' и ',' десятичный
+	dec := ""
+	dot, comma := strings.Count(s, "."), strings.Count(s, ",")
+	switch {
+	case dot > 0 && comma > 0:
+		dec = "."
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			dec = ","
+		}
+	case dot == 1 || comma == 1:
+		dec = "."
+		if comma == 1 {
+			dec = ","
+		}
+		if i := strings.Index(s, dec); len(s)-i-1 == 3 {
+			switch f := cur.format(); dec {
+			case f.decimal:
+			case f.group:
+				dec = "" // "1,299" в долларах — это разряды
+			default:
+				return fail(ErrAmbiguous)
+			}
+		}
+	}
+
+	intPart, fracPart := s, ""
+	if dec != "" {
+		i := strings.LastIndex(s, dec)
+		intPart, fracPart = s[:i], s[i+1:]
+		if fracPart == "" || !digits(fracPart) {
+			return fail(ErrSyntax)
+		}
+	}
+	intPart, err := ungroup(intPart)
+	if err != nil {
+		return fail(err)
+	}
+
+	n := cur.format().fraction
+	if len(fracPart) > n {
+		return fail(ErrFraction)
+	}
+	fracPart += strings.Repeat("0", n-len(fracPart))
+
+	amount, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return fail(ErrRange)
+	}
+	if neg {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: cur}, nil
+}
+
+// ungroup убирает разделители разрядов из целой части.
+// Разделитель один на всё число, первая группа 1–3 цифры без ведущего нуля, остальные ровно по 3.
+func ungroup(s string) (string, error) {
+	sep := rune(0)
+	for _, r := range s {
+		if r == '.' || r == ',' || strings.ContainsRune(spaceSeparators, r) {
+			if sep != 0 && r != sep {
+				return "", ErrSyntax
+			}
+			sep = r
+		}
+	}
+	if sep == 0 {
+		if s == "" || !digits(s) {
+			return "", ErrSyntax
+		}
+		return s, nil
+	}
+	groups := strings.Split(s, string(sep))
+	for i, g := range groups {
+		if !digits(g) || (i == 0 && (len(g) == 0 || len(g) > 3 || g[0] == '0')) || (i > 0 && len(g) != 3) {
+			return "", ErrSyntax
+		}
+	}
+	return strings.Join(groups, ""), nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// split — целая и дробная части модуля суммы строками
+func (m Money) split() (intPart, fracPart string) {
+	n := m.Currency.format().fraction
+	a := m.Amount
+	if a < 0 {
+		a = -a
+	}
+	s := strconv.FormatInt(a, 10)
+	if n == 0 {
+		return s, ""
+	}
+	if len(s) <= n {
+		s = strings.Repeat("0", n-len(s)+1) + s
+	}
+	return s[:len(s)-n], s[len(s)-n:]
+}
+
+// Decimal — сумма без символа и разрядов, с точкой: "1299.50". Годится для value в формах и Parse.
+func (m Money) Decimal() string {
+	i, f := m.split()
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if f == "" {
+		return sign + i
+	}
+	return sign + i + "." + f
+}
+
+// String — сумма для показа по правилам валюты: "$1,299.50", "1 299,50 ₽"
+func (m Money) String() string {
+	f := m.Currency.format()
+	i, frac := m.split()
+	var b strings.Builder
+	for k, r := range i {
+		if k > 0 && (len(i)-k)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(r)
+	}
+	num := b.String()
+	if frac != "" {
+		num += f.decimal + frac
+	}
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if f.prefix || f.symbol == "" {
+		return sign + f.symbol + num
+	}
+	return sign + num + " " + f.symbol
+}
+
+// Supported — валюты, которые можно выбрать для цены товара и для показа
+var Supported = []Currency{RUB, USD, EUR}
+
+// Convert переводит сумму в валюту to по курсу rate (сколько to за 1 единицу m.Currency).
+// Округление до минимальной единицы to, половина — от нуля.
+func (m Money) Convert(to Currency, rate float64) Money {
+	if m.Currency == to {
+		return m
+	}
+	shift := to.format().fraction - m.Currency.format().fraction
+	v := float64(m.Amount) * rate * math.Pow10(shift)
+	return Money{Amount: int64(math.Round(v)), Currency: to}
+}
@@ -0,0 +1,129 @@
+package money
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// валюта с тремя знаками после точки, как динар
+	formats["KWD"] = format{symbol: "KD", prefix: true, group: ",", decimal: ".", fraction: 3}
+	t.Cleanup(func() { delete(formats, "KWD") })
+
+	tests := []struct {
+		in   string
+		cur  Currency
+		want int64
+		err  error
+	}{
+		{"1299", USD, 129900, nil},
+		{"1299.5", USD, 129950, nil},
+		{"1 299,50", RUB, 129950, nil},
+		{"1\u00a0299,50", RUB, 129950, nil},
+		{"1,299.50", USD, 129950, nil},
+		{"1.299,50", EUR, 129950, nil},
+		{"12,345,678", USD, 1234567800, nil},
+		{"0,5", RUB, 50, nil},
+		{"0.50", USD, 50, nil},
+		{"  -12.30 ", USD, -1230, nil},
+
+		// с нуля разряды не начинаются: это дробная часть, и знаков в ней слишком много
+		{"0.001", USD, 0, ErrFraction},
+		{"0,500", RUB, 0, ErrFraction},
+		{"0.500", USD, 0, ErrFraction},
+		{"01,299", USD, 0, ErrSyntax},
+		{"0 500", RUB, 0, ErrSyntax},
+		{"0,500,000", USD, 0, ErrSyntax},
+		{"01 299,50", RUB, 0, ErrSyntax},
+
+		// один разделитель и три цифры: разряды или дробь — по правилам валюты
+		{"1,299", USD, 129900, nil},
+		{"1.999", USD, 0, ErrFraction},
+		{"1,500", RUB, 0, ErrFraction},
+		{"1.500", RUB, 0, ErrAmbiguous},
+		{"1.299", EUR, 0, ErrAmbiguous},
+		{"1,299", "XYZ", 0, ErrAmbiguous},
+		{"1.999", "XYZ", 0, ErrFraction},
+		{"1.999", "KWD", 1999, nil},
+		{"1,999", "KWD", 1999000, nil},
+		{"1,999.5", "KWD", 1999500, nil},
+		{"0.001", "KWD", 1, nil},
+		{"1.5", RUB, 150, nil}, // не три цифры — однозначно дробь
+		{"1,5", USD, 150, nil},
+
+		{"", USD, 0, ErrEmpty},
+		{" - ", USD, 0, ErrEmpty},
+		{"12a", USD, 0, ErrSyntax},
+		{"1.2.3", USD, 0, ErrSyntax},
+		{"1,2,3", USD, 0, ErrSyntax},
+		{"1 299.000,5", EUR, 0, ErrSyntax},
+		{"1.", USD, 0, ErrSyntax},
+		{".5", USD, 0, ErrSyntax},
+		{"1,2345", USD, 0, ErrFraction},
+		{"99999999999999999999", USD, 0, ErrRange},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.cur)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Parse(%q) = %v, %v; want error %v", tt.in, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != New(tt.want, tt.cur) {
+			t.Errorf("Parse(%q) = %v, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(129950, USD), "$1,299.50"},
+		{New(-5, USD), "-$0.05"},
+		{New(129950, RUB), "1\u00a0299,50\u00a0₽"},
+		{New(100, "XYZ"), "1.00\u00a0XYZ"},
+		{Money{}, "0.00"},
+		{New(-123456789, ""), "-1\u00a0234\u00a0567.89"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+// bare — сумма для показа без символа валюты, как её ввёл бы продавец
+func bare(m Money) string {
+	return strings.TrimSpace(strings.Replace(m.String(), m.Currency.Symbol(), "", 1))
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{"1299", "1 299,50", "1,299.50", "1.299,50", "0.001", "0,500", "-12.30", "1\u00a0299", "1'299.5", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, cur := range []Currency{RUB, USD, EUR, "XYZ", ""} {
+			m, err := Parse(s, cur)
+			if err != nil {
+				var pe *ParseError
+				if !errors.As(err, &pe) {
+					t.Fatalf("Parse(%q, %s): error %v is not a *ParseError", s, cur, err)
+				}
+				continue
+			}
+			for _, out := range []string{bare(m), m.Decimal()} {
+				again, err := Parse(out, cur)
+				if err != nil {
+					t.Fatalf("Parse(%q, %s) = %v, but its output %q does not parse: %v", s, cur, m, out, err)
+				}
+				if again != m {
+					t.Fatalf("Parse(%q, %s) = %v; round trip through %q gives %v", s, cur, m, out, again)
+				}
+			}
+		}
+	})
+}
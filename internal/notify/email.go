@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Email — отправка через SMTP-сервер (STARTTLS, если сервер его предлагает)
+type Email struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+func (Email) Name() string { return "email" }
+
+func (e Email) Send(_ context.Context, m Message) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, _ := net.SplitHostPort(e.Addr)
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return smtp.SendMail(e.Addr, auth, e.From, []string{m.To}, []byte(b.String()))
+}
+
+// FromEnv — email, если задан SMTP_HOST (SMTP_PORT, SMTP_USER, SMTP_PASSWORD, MAIL_FROM), иначе лог
+func FromEnv() Channel {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return Log{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@" + host
+	}
+	return Email{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
@@ -0,0 +1,95 @@
+// Package notify — доставка уведомлений из очереди models.Notification по каналам (email, лог).
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// Message — уведомление, готовое к отправке
+type Message struct {
+	To      string // адрес в терминах канала (для email — почта)
+	Subject string
+	Body    string
+}
+
+// Channel — способ доставки. Новые каналы (SMS, Telegram) подключаются через Dispatcher.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// Log — канал для разработки: пишет уведомления в лог
+type Log struct{}
+
+func (Log) Name() string { return "log" }
+
+func (Log) Send(_ context.Context, m Message) error {
+	log.Printf("notify to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// errNoAddress — у пользователя нет адреса для канала
+var errNoAddress = errors.New("no email")
+
+// MaxAttempts — после стольких неудачных попыток уведомление больше не отправляем
+const MaxAttempts = 5
+
+// batch — сколько уведомлений отправляем за один проход
+const batch = 100
+
+// Dispatcher отправляет накопившиеся уведомления
+type Dispatcher struct {
+	DB      *gorm.DB
+	Channel Channel
+	BaseURL string // для ссылок в письмах, напр. "https://market.example"
+}
+
+// Run — один проход по очереди; для jobs.Every
+func (d Dispatcher) Run(ctx context.Context) error {
+	var list []models.Notification
+	err := d.DB.Where("sent_at IS NULL AND attempts < ?", MaxAttempts).Order("id").Limit(batch).Find(&list).Error
+	if err != nil {
+		return err
+	}
+	for _, n := range list {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		upd := map[string]any{"attempts": n.Attempts + 1}
+		if err := d.send(ctx, n); err != nil {
+			upd["error"] = err.Error()
+			if errors.Is(err, errNoAddress) {
+				upd["attempts"] = MaxAttempts // доставить нечем — больше не пытаемся
+			}
+		} else {
+			upd["sent_at"], upd["error"] = time.Now(), ""
+		}
+		if err := d.DB.Model(&n).Updates(upd).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Dispatcher) send(ctx context.Context, n models.Notification) error {
+	var u models.User
+	if err := d.DB.First(&u, n.UserID).Error; err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return fmt.Errorf("user #%d: %w", u.ID, errNoAddress)
+	}
+	body := n.Body
+	if n.Link != "" {
+		body += "\n\n" + d.BaseURL + n.Link
+	}
+	return d.Channel.Send(ctx, Message{To: u.Email, Subject: n.Subject, Body: body})
+}
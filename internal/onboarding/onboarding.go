@@ -0,0 +1,230 @@
+// Package onboarding — анкета продавца (реквизиты, документы) и её проверка модератором.
+// Пока анкета не одобрена, продавец может готовить черновики, но не публиковать товары
+// и не получать выплаты (models.SellerVerified).
+package onboarding
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// Dir — куда сохраняются документы продавцов (наружу не раздаётся, только через модератора)
+const Dir = "verification"
+
+// MaxFileSize — предел размера одного документа
+const MaxFileSize = 10 << 20
+
+// MaxDocuments — сколько документов можно приложить к анкете
+const MaxDocuments = 10
+
+// Extensions — допустимые типы документов
+var Extensions = []string{".pdf", ".jpg", ".jpeg", ".png"}
+
+// Input — поля анкеты как их ввели в форме
+type Input struct {
+	LegalForm   string
+	LegalName   string
+	INN         string
+	OGRN        string
+	BankName    string
+	BIK         string
+	Account     string
+	CorrAccount string
+}
+
+// FromVerification — Input с текущими значениями анкеты
+func FromVerification(v models.SellerVerification) Input {
+	return Input{
+		LegalForm: string(v.LegalForm), LegalName: v.LegalName, INN: v.INN, OGRN: v.OGRN,
+		BankName: v.BankName, BIK: v.BIK, Account: v.Account, CorrAccount: v.CorrAccount,
+	}
+}
+
+// Validate проверяет анкету и переносит поля в v. Контрольные цифры ИНН, ОГРН и счёта
+// считаются по правилам ФНС и ЦБ — опечатку видно сразу, а не на выплате.
+func (in Input) Validate(v *models.SellerVerification) error {
+	form := models.LegalForm(strings.TrimSpace(in.LegalForm))
+	name := strings.TrimSpace(in.LegalName)
+	inn, ogrn := digits(in.INN), digits(in.OGRN)
+	if !form.Valid() {
+		return fmt.Errorf("choose a legal form")
+	}
+	if name == "" {
+		return fmt.Errorf("enter the full name or company name")
+	}
+	innLen := 12
+	if form == models.LegalOOO {
+		innLen = 10
+	}
+	if len(inn) != innLen || !innValid(inn) {
+		return fmt.Errorf("tax number (INN) must be %d digits with a valid check digit", innLen)
+	}
+	switch form {
+	case models.LegalIP:
+		if len(ogrn) != 15 || !ogrnValid(ogrn) {
+			return fmt.Errorf("registration number (OGRNIP) must be 15 digits with a valid check digit")
+		}
+	case models.LegalOOO:
+		if len(ogrn) != 13 || !ogrnValid(ogrn) {
+			return fmt.Errorf("registration number (OGRN) must be 13 digits with a valid check digit")
+		}
+	default:
+		ogrn = ""
+	}
+	if err := in.ValidateBank(v); err != nil {
+		return err
+	}
+	v.LegalForm, v.LegalName, v.INN, v.OGRN = form, name, inn, ogrn
+	return nil
+}
+
+// ValidateBank проверяет только банковские реквизиты и переносит их в v
+func (in Input) ValidateBank(v *models.SellerVerification) error {
+	bik, account, corr := digits(in.BIK), digits(in.Account), digits(in.CorrAccount)
+	if len(bik) != 9 {
+		return fmt.Errorf("bank identifier (BIK) must be 9 digits")
+	}
+	if len(account) != 20 || !accountValid(bik, account) {
+		return fmt.Errorf("bank account must be 20 digits and match the BIK")
+	}
+	if corr != "" && len(corr) != 20 {
+		return fmt.Errorf("correspondent account must be 20 digits")
+	}
+	v.BankName, v.BIK, v.Account, v.CorrAccount = strings.TrimSpace(in.BankName), bik, account, corr
+	return nil
+}
+
+// digits — строка без пробелов и дефисов, которыми реквизиты часто разбивают при вводе
+func digits(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s))
+}
+
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// checkDigit — сумма цифр s с весами w по модулю m, затем по модулю 10
+func checkDigit(s string, w []int, m int) int {
+	sum := 0
+	for i, k := range w {
+		sum += int(s[i]-'0') * k
+	}
+	return sum % m % 10
+}
+
+// innValid — контрольные цифры ИНН (10 цифр — организация, 12 — физлицо и ИП)
+func innValid(inn string) bool {
+	if !onlyDigits(inn) {
+		return false
+	}
+	switch len(inn) {
+	case 10:
+		return checkDigit(inn, []int{2, 4, 10, 3, 5, 9, 4, 6, 8}, 11) == int(inn[9]-'0')
+	case 12:
+		return checkDigit(inn, []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}, 11) == int(inn[10]-'0') &&
+			checkDigit(inn, []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}, 11) == int(inn[11]-'0')
+	}
+	return false
+}
+
+// ogrnValid — контрольная цифра ОГРН (13 цифр, по модулю 11) и ОГРНИП (15 цифр, по модулю 13)
+func ogrnValid(ogrn string) bool {
+	if !onlyDigits(ogrn) {
+		return false
+	}
+	mod := uint64(11)
+	if len(ogrn) == 15 {
+		mod = 13
+	}
+	var n uint64
+	for _, r := range ogrn[:len(ogrn)-1] {
+		n = (n*10 + uint64(r-'0')) % mod
+	}
+	return n%10 == uint64(ogrn[len(ogrn)-1]-'0')
+}
+
+// accountValid — контрольный ключ расчётного счёта вместе с тремя последними цифрами БИК
+func accountValid(bik, account string) bool {
+	s := bik[len(bik)-3:] + account
+	if !onlyDigits(s) {
+		return false
+	}
+	w := []int{7, 1, 3}
+	sum := 0
+	for i := range s {
+		sum += int(s[i]-'0') * w[i%3] % 10
+	}
+	return sum%10 == 0
+}
+
+// Submit отправляет анкету на проверку; нужен хотя бы один документ
+func Submit(db *gorm.DB, v *models.SellerVerification) error {
+	if !v.Editable() {
+		return fmt.Errorf("application is already %s", v.Status)
+	}
+	if v.INN == "" {
+		return fmt.Errorf("fill in and save your legal details first")
+	}
+	if len(v.Documents) == 0 {
+		return fmt.Errorf("attach at least one document")
+	}
+	now := time.Now()
+	res := db.Model(v).Where("status IN ?", []models.VerificationStatus{models.VerificationDraft, models.VerificationRejected}).
+		Updates(map[string]any{"status": models.VerificationPending, "submitted_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("application was changed, reload the page")
+	}
+	v.Status, v.SubmittedAt = models.VerificationPending, &now
+	return nil
+}
+
+// Review — решение модератора по анкете в статусе pending; продавцу уходит уведомление
+func Review(db *gorm.DB, v *models.SellerVerification, reviewerID uint, approve bool, reason string) error {
+	reason = strings.TrimSpace(reason)
+	status, n := models.VerificationApproved, models.Notification{
+		UserID:  v.SellerID,
+		Kind:    models.NotifyVerification,
+		Subject: "Your seller account is verified",
+		Body:    "You can now publish products and receive payouts.",
+		Link:    "/seller/products",
+	}
+	if approve {
+		reason = ""
+	} else {
+		if reason == "" {
+			return fmt.Errorf("give a reason so the seller knows what to fix")
+		}
+		status, n.Subject, n.Body, n.Link = models.VerificationRejected,
+			"Your seller application needs changes", reason, "/seller/onboarding"
+	}
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(v).Where("status = ?", models.VerificationPending).Updates(map[string]any{
+			"status":        status,
+			"reviewer_id":   reviewerID,
+			"reviewed_at":   now,
+			"reject_reason": reason,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("application is no longer pending")
+		}
+		v.Status, v.RejectReason = status, reason
+		return models.Notify(tx, n)
+	})
+}
@@ -0,0 +1,100 @@
+// Package orders — оформление заказа из корзины и его доставка.
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/fx"
+	models "marketplace/internal/models"
+	"marketplace/internal/money"
+)
+
+var (
+	ErrEmptyCart  = errors.New("cart is empty")
+	ErrOutOfStock = errors.New("not enough stock")
+	ErrSellerAway = errors.New("seller is on vacation")
+)
+
+// Line — строка корзины
+type Line struct {
+	ProductID uint
+	Qty       int
+}
+
+// Place оформляет заказ в ожидании оплаты: резервирует (списывает) остатки, фиксирует цены
+// в валюте расчётов settlement и создаёт Order с позициями. Всё в одной транзакции: либо заказ
+// целиком, либо ничего. Продажа в книге и отправления появляются после оплаты (Pay).
+func Place(db *gorm.DB, buyerID uint, lines []Line, rates fx.Table, settlement money.Currency) (*models.Order, error) {
+	if len(lines) == 0 {
+		return nil, ErrEmptyCart
+	}
+	// одинаковый порядок блокировок строк — меньше шансов на дедлок между параллельными заказами
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+
+	order := &models.Order{BuyerID: buyerID, Status: models.OrderPending, Currency: settlement}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var moves []uint // движения остатков: номер заказа проставим, когда он появится
+		for _, l := range lines {
+			if l.Qty <= 0 {
+				continue
+			}
+			var p models.Product
+			if err := tx.Scopes(models.ListedProducts).First(&p, "id = ?", l.ProductID).Error; err != nil {
+				return fmt.Errorf("product #%d is no longer available", l.ProductID)
+			}
+			if away, err := models.SellerAway(tx, p.SellerID); err != nil {
+				return err
+			} else if away != nil {
+				return fmt.Errorf("%w: %s", ErrSellerAway, p.Title)
+			}
+			rate, err := rates.Rate(p.Currency, settlement)
+			if err != nil {
+				return err
+			}
+			// условное списание: при гонке двух покупателей второй получит ErrOutOfStock.
+			// Склад выбирается по приоритету продавца (models.MoveStock)
+			m := models.InventoryMovement{ProductID: p.ID, Delta: -l.Qty, Reason: models.MoveSale, ActorID: buyerID}
+			ms, err := models.MoveStock(tx, m)
+			if errors.Is(err, models.ErrInsufficientStock) {
+				return fmt.Errorf("%w: %s", ErrOutOfStock, p.Title)
+			} else if err != nil {
+				return err
+			}
+			var warehouse *uint
+			for _, m := range ms {
+				moves = append(moves, m.ID)
+			}
+			if len(ms) == 1 {
+				warehouse = ms[0].WarehouseID
+			}
+			unit := p.Price().Convert(settlement, rate)
+			order.Items = append(order.Items, models.OrderItem{
+				ProductID:    p.ID,
+				SellerID:     p.SellerID,
+				Title:        p.Title,
+				Qty:          l.Qty,
+				UnitAmount:   unit.Amount,
+				ListAmount:   p.Price().Amount,
+				ListCurrency: p.Currency,
+				Rate:         rate,
+				WarehouseID:  warehouse,
+			})
+			order.TotalAmount += unit.Amount * int64(l.Qty)
+		}
+		if len(order.Items) == 0 {
+			return ErrEmptyCart
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.InventoryMovement{}).Where("id IN ?", moves).Update("order_id", order.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
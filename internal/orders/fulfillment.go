@@ -0,0 +1,268 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"marketplace/internal/ledger"
+	models "marketplace/internal/models"
+)
+
+// DefaultAutoConfirm — через сколько после отправки получение считается подтверждённым
+const DefaultAutoConfirm = 14 * 24 * time.Hour
+
+var (
+	ErrNoShipment    = errors.New("shipment not found")
+	ErrShipmentState = errors.New("shipment is not in the right state")
+)
+
+// createShipments — по отправлению на каждого продавца заказа, в порядке позиций
+func createShipments(tx *gorm.DB, order *models.Order) error {
+	seen := map[uint]bool{}
+	for _, it := range order.Items {
+		if seen[it.SellerID] {
+			continue
+		}
+		seen[it.SellerID] = true
+		order.Shipments = append(order.Shipments, models.Shipment{OrderID: order.ID, SellerID: it.SellerID, Status: models.ShipmentAwaiting})
+	}
+	return tx.Create(&order.Shipments).Error
+}
+
+// lockShipment — отправление продавца в заказе под блокировкой
+func lockShipment(tx *gorm.DB, orderID, sellerID uint) (*models.Shipment, error) {
+	var s models.Shipment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&s, "order_id = ? AND seller_id = ?", orderID, sellerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoShipment
+	}
+	return &s, err
+}
+
+// syncStatus — статус заказа по его отправлениям: самое отстающее из них
+func syncStatus(tx *gorm.DB, orderID uint) error {
+	var list []models.Shipment
+	if err := tx.Select("status").Where("order_id = ?", orderID).Find(&list).Error; err != nil {
+		return err
+	}
+	status := models.OrderDelivered
+	live := 0
+	for _, s := range list {
+		switch s.Status {
+		case models.ShipmentCancelled:
+			continue
+		case models.ShipmentAwaiting:
+			status = models.OrderPaid
+		case models.ShipmentShipped:
+			if status == models.OrderDelivered {
+				status = models.OrderShipped
+			}
+		}
+		live++
+	}
+	if live == 0 {
+		status = models.OrderCancelled
+	}
+	return tx.Model(&models.Order{}).Where("id = ?", orderID).Update("status", status).Error
+}
+
+// Ship — продавец передал свою часть заказа в доставку; с этого момента идёт срок автоподтверждения
+func Ship(db *gorm.DB, orderID, sellerID uint, carrier, tracking string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		s, err := lockShipment(tx, orderID, sellerID)
+		if err != nil {
+			return err
+		}
+		if s.Status != models.ShipmentAwaiting {
+			return ErrShipmentState
+		}
+		var order models.Order
+		if err := tx.Select("id", "buyer_id").First(&order, orderID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		err = tx.Model(s).Updates(map[string]any{
+			"status": models.ShipmentShipped, "shipped_at": now, "carrier": carrier, "tracking_number": tracking,
+		}).Error
+		if err != nil {
+			return err
+		}
+		body := fmt.Sprintf("Part of your order #%d is on its way.", orderID)
+		if tracking != "" {
+			body += fmt.Sprintf(" Tracking: %s %s.", carrier, tracking)
+		}
+		err = models.Notify(tx, models.Notification{
+			UserID:  order.BuyerID,
+			Kind:    models.NotifyShipped,
+			Subject: fmt.Sprintf("Order #%d shipped", orderID),
+			Body:    body + " Please confirm delivery once you receive it.",
+			Link:    fmt.Sprintf("/orders/%d", orderID),
+		})
+		if err != nil {
+			return err
+		}
+		return syncStatus(tx, orderID)
+	})
+}
+
+// deliver закрывает отправление. Выручка остаётся на удержании ещё на срок возврата —
+// её переведёт в доступные ledger.ReleaseDue, если по отправлению не будет спора.
+func deliver(tx *gorm.DB, s *models.Shipment, auto bool) error {
+	now := time.Now()
+	err := tx.Model(s).Updates(map[string]any{"status": models.ShipmentDelivered, "delivered_at": now, "auto_confirmed": auto}).Error
+	if err != nil {
+		return err
+	}
+	body := "The buyer has received the order. Its proceeds become available for payout once the return period ends."
+	if s.Frozen() {
+		body = "The buyer has received the order. Its proceeds stay on hold until the dispute is resolved."
+	}
+	subject := fmt.Sprintf("Order #%d delivered", s.OrderID)
+	if auto {
+		subject = fmt.Sprintf("Order #%d auto-confirmed", s.OrderID)
+	}
+	err = models.Notify(tx, models.Notification{
+		UserID:  s.SellerID,
+		Kind:    models.NotifyDelivered,
+		Subject: subject,
+		Body:    body,
+		Link:    "/seller/balance",
+	})
+	if err != nil {
+		return err
+	}
+	return syncStatus(tx, s.OrderID)
+}
+
+// Confirm — покупатель подтвердил получение части заказа от продавца.
+// Можно и до отметки об отправке: продавец мог забыть её поставить.
+func Confirm(db *gorm.DB, orderID, buyerID, sellerID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.Order{}).Where("id = ? AND buyer_id = ?", orderID, buyerID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoShipment
+		}
+		s, err := lockShipment(tx, orderID, sellerID)
+		if err != nil {
+			return err
+		}
+		if s.Status == models.ShipmentDelivered || s.Status == models.ShipmentCancelled {
+			return ErrShipmentState
+		}
+		return deliver(tx, s, false)
+	})
+}
+
+// AutoConfirm подтверждает получение отправлений, отправленных раньше timeout назад.
+// Отправления со спором ждут его решения. Возвращает число подтверждённых.
+func AutoConfirm(db *gorm.DB, timeout time.Duration, now time.Time) (int, error) {
+	var due []models.Shipment
+	err := db.Select("order_id", "seller_id").
+		Where("status = ? AND shipped_at <= ? AND frozen_at IS NULL", models.ShipmentShipped, now.Add(-timeout)).
+		Order("id").Limit(500).
+		Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range due {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			s, err := lockShipment(tx, d.OrderID, d.SellerID)
+			if err != nil {
+				return err
+			}
+			// между выборкой и блокировкой покупатель мог подтвердить сам или открыть спор
+			if s.Status != models.ShipmentShipped || s.Frozen() {
+				return nil
+			}
+			n++
+			return deliver(tx, s, true)
+		})
+		if err != nil {
+			return n, fmt.Errorf("order #%d seller #%d: %w", d.OrderID, d.SellerID, err)
+		}
+	}
+	return n, nil
+}
+
+// CancelRefunded отменяет часть заказа продавца, если её ещё не отправили, а покупателю
+// по спорам вернули всю её стоимость. Отправленные части не отменяются: возврат после отправки — не отмена.
+func CancelRefunded(tx *gorm.DB, orderID, sellerID uint) error {
+	s, err := lockShipment(tx, orderID, sellerID)
+	if err != nil || s.Status != models.ShipmentAwaiting {
+		return err
+	}
+	var sums struct{ Paid, Refunded int64 }
+	err = tx.Raw(`
+		SELECT (SELECT COALESCE(SUM(unit_amount * qty), 0) FROM order_items WHERE order_id = ? AND seller_id = ?) AS paid,
+		       (SELECT COALESCE(SUM(refund_amount), 0) FROM disputes WHERE order_id = ? AND seller_id = ? AND outcome = ?) AS refunded`,
+		orderID, sellerID, orderID, sellerID, models.OutcomeRefund).
+		Scan(&sums).Error
+	if err != nil || sums.Refunded < sums.Paid {
+		return err
+	}
+	if err := tx.Model(s).Update("status", models.ShipmentCancelled).Error; err != nil {
+		return err
+	}
+	return syncStatus(tx, orderID)
+}
+
+// OpenCancellations отменяет части заказов, полностью возвращённые до отправки раньше,
+// чем появился статус cancelled. Повторный вызов ничего не делает.
+func OpenCancellations(db *gorm.DB) error {
+	var list []models.Shipment
+	err := db.Select("order_id", "seller_id").
+		Where("status = ? AND EXISTS (SELECT 1 FROM disputes d WHERE d.order_id = shipments.order_id AND d.seller_id = shipments.seller_id AND d.outcome = ?)",
+			models.ShipmentAwaiting, models.OutcomeRefund).
+		Find(&list).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		if err := db.Transaction(func(tx *gorm.DB) error { return CancelRefunded(tx, s.OrderID, s.SellerID) }); err != nil {
+			return fmt.Errorf("order #%d seller #%d: %w", s.OrderID, s.SellerID, err)
+		}
+	}
+	return nil
+}
+
+// Freeze замораживает выручку по отправлению на время спора. Когда удержание уже закончилось
+// (выручка доступна продавцу), заморозить нельзя — тогда спор решается возвратом.
+func Freeze(tx *gorm.DB, orderID, sellerID uint, reason string) error {
+	s, err := lockShipment(tx, orderID, sellerID)
+	if err != nil {
+		return err
+	}
+	if s.Frozen() {
+		return nil
+	}
+	if s.Status == models.ShipmentDelivered {
+		if done, err := ledger.Released(tx, orderID, sellerID); err != nil {
+			return err
+		} else if done {
+			return ErrShipmentState
+		}
+	}
+	return tx.Model(s).Updates(map[string]any{"frozen_at": time.Now(), "freeze_reason": reason}).Error
+}
+
+// Unfreeze снимает заморозку после решения спора. Если товар уже получен, выручку отпустит
+// ledger.ReleaseDue по окончании удержания; если ещё в пути — срок автоподтверждения продолжает идти.
+func Unfreeze(tx *gorm.DB, orderID, sellerID uint) error {
+	s, err := lockShipment(tx, orderID, sellerID)
+	if err != nil {
+		return err
+	}
+	if !s.Frozen() {
+		return nil
+	}
+	return tx.Model(s).Updates(map[string]any{"frozen_at": nil, "freeze_reason": ""}).Error
+}
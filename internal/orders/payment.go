@@ -0,0 +1,84 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"marketplace/internal/ledger"
+	models "marketplace/internal/models"
+	"marketplace/internal/payments"
+)
+
+// ErrNotPending — заказ уже оплачен или оплата отклонена
+var ErrNotPending = errors.New("order is not awaiting payment")
+
+// Pay списывает деньги за заказ в ожидании оплаты. Успех — заказ оплачен: продажа в книге
+// (выручка продавцов на удержании) и отправления продавцам. Отказ шлюза (payments.ErrDeclined) —
+// заказ закрывается, резерв возвращается на склад. Другие ошибки оставляют заказ в ожидании:
+// Pay можно повторить, шлюз не спишет деньги дважды.
+func Pay(ctx context.Context, db *gorm.DB, provider payments.Provider, orderID uint) (*models.Order, error) {
+	var order models.Order
+	if err := db.Preload("Items").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderPending {
+		return &order, ErrNotPending
+	}
+	ref, payErr := provider.Charge(ctx, order)
+	if payErr != nil && !errors.Is(payErr, payments.ErrDeclined) {
+		return &order, payErr
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		cols := map[string]any{"status": models.OrderPaid, "payment_provider": provider.Name(), "payment_ref": ref, "paid_at": time.Now()}
+		if payErr != nil {
+			cols = map[string]any{"status": models.OrderPaymentFailed, "payment_provider": provider.Name()}
+		}
+		res := tx.Model(&order).Where("status = ?", models.OrderPending).Updates(cols)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotPending // результат записал параллельный запрос
+		}
+		if payErr != nil {
+			return unreserve(tx, &order)
+		}
+		// выручка продавцов и комиссия площадки — на удержании до получения заказа
+		if err := ledger.RecordSale(tx, &order); err != nil {
+			return err
+		}
+		return createShipments(tx, &order)
+	})
+	if err != nil {
+		return &order, err
+	}
+	return &order, payErr
+}
+
+// unreserve возвращает на склады то, что заказ списал при оформлении
+func unreserve(tx *gorm.DB, order *models.Order) error {
+	var moves []models.InventoryMovement
+	if err := tx.Where("order_id = ? AND reason = ?", order.ID, models.MoveSale).Order("id").Find(&moves).Error; err != nil {
+		return err
+	}
+	for _, m := range moves {
+		back := models.InventoryMovement{
+			ProductID: m.ProductID, WarehouseID: m.WarehouseID, Delta: -m.Delta, Reason: models.MoveCancel,
+			ActorID: order.BuyerID, OrderID: &order.ID, Note: fmt.Sprintf("order #%d not paid", order.ID),
+		}
+		// товар могли убрать в архив, а склад — удалить: резерв возвращается всё равно
+		_, err := models.MoveStock(tx.Unscoped(), back)
+		if errors.Is(err, models.ErrWarehouse) {
+			back.WarehouseID = nil
+			_, err = models.MoveStock(tx.Unscoped(), back)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Package payments — списание денег покупателя за заказ через платёжный шлюз.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	models "marketplace/internal/models"
+)
+
+// ErrDeclined — шлюз отказал в оплате (нет денег, банк не подтвердил); заказ отменяется
+var ErrDeclined = errors.New("payment declined")
+
+// Provider — платёжный шлюз. Charge должен быть идемпотентным по order.ID:
+// после сбоя тот же заказ оплачивается повторно, и деньги не списываются дважды.
+// Отказ — ошибка, обёрнутая в ErrDeclined; прочие ошибки (сеть, таймаут) оставляют заказ в ожидании оплаты.
+type Provider interface {
+	Name() string
+	Charge(ctx context.Context, order models.Order) (reference string, err error)
+}
+
+// Fake — шлюз для разработки: ничего не списывает, оплата проходит всегда
+type Fake struct{}
+
+func (Fake) Name() string { return "fake" }
+
+func (Fake) Charge(_ context.Context, o models.Order) (string, error) {
+	log.Printf("payment: order #%d, %s from buyer #%d", o.ID, o.Total(), o.BuyerID)
+	return fmt.Sprintf("fake-%d", o.ID), nil
+}
+
+// ProviderFromEnv — шлюз по PAYMENT_PROVIDER; пока есть только fake
+func ProviderFromEnv() (Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "fake":
+		return Fake{}, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+	}
+}
@@ -0,0 +1,213 @@
+// Package reviews — отзывы покупателей о товарах из полученных заказов, ответы продавцов и модерация;
+// оценки продавцов и их репутация.
+package reviews
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// EditWindow — сколько можно править отзыв после того, как он оставлен
+const EditWindow = 30 * 24 * time.Hour
+
+// MaxPhotos — фото к отзыву
+const MaxPhotos = 5
+
+// MaxLength — длина текста отзыва и ответа продавца
+const MaxLength = 5000
+
+var (
+	ErrNotDelivered = errors.New("you can review an item once you have received it")
+	ErrLocked       = errors.New("the review can no longer be edited")
+	ErrInvalid      = errors.New("invalid review")
+)
+
+// Input — отзыв из формы. Photos заменяют прежние фото; пусто — фото не меняются.
+type Input struct {
+	Rating int
+	Body   string
+	Photos []string
+}
+
+func (in *Input) validate() error {
+	in.Body = strings.TrimSpace(in.Body)
+	switch {
+	case in.Rating < 1 || in.Rating > 5:
+		return fmt.Errorf("%w: rating must be 1 to 5 stars", ErrInvalid)
+	case len([]rune(in.Body)) > MaxLength:
+		return fmt.Errorf("%w: up to %d characters", ErrInvalid, MaxLength)
+	case len(in.Photos) > MaxPhotos:
+		return fmt.Errorf("%w: up to %d photos", ErrInvalid, MaxPhotos)
+	}
+	return nil
+}
+
+// Editable — отзыв ещё можно менять
+func Editable(r models.Review, now time.Time) bool {
+	return now.Sub(r.CreatedAt) <= EditWindow
+}
+
+// Of — отзыв на позицию заказа; nil, если его нет
+func Of(db *gorm.DB, itemID uint) (*models.Review, error) {
+	var r models.Review
+	err := db.Preload("Photos").First(&r, "order_item_id = ?", itemID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Save оставляет или правит отзыв покупателя на позицию заказа. Отзыв можно оставить,
+// когда продавец доставил свою часть заказа, а править — EditWindow после создания.
+func Save(db *gorm.DB, buyerID uint, item models.OrderItem, in Input) (*models.Review, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	var review *models.Review
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		err := tx.Model(&models.Shipment{}).
+			Joins("JOIN orders o ON o.id = shipments.order_id").
+			Where("shipments.order_id = ? AND shipments.seller_id = ? AND shipments.status = ? AND o.buyer_id = ?",
+				item.OrderID, item.SellerID, models.ShipmentDelivered, buyerID).
+			Count(&n).Error
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotDelivered
+		}
+		r, err := Of(tx, item.ID)
+		if err != nil {
+			return err
+		}
+		created := r == nil
+		if created {
+			r = &models.Review{
+				ProductID: item.ProductID, OrderItemID: item.ID, BuyerID: buyerID, SellerID: item.SellerID,
+				Status: models.ReviewPublished,
+			}
+		} else if !Editable(*r, time.Now()) {
+			return ErrLocked
+		}
+		r.Rating, r.Body = in.Rating, in.Body
+		if created {
+			err = tx.Create(r).Error
+		} else {
+			err = tx.Model(r).Updates(map[string]any{"rating": r.Rating, "body": r.Body}).Error
+		}
+		if err != nil {
+			return err
+		}
+		if len(in.Photos) > 0 {
+			if err := tx.Where("review_id = ?", r.ID).Delete(&models.ReviewPhoto{}).Error; err != nil {
+				return err
+			}
+			r.Photos = r.Photos[:0]
+			for _, p := range in.Photos {
+				r.Photos = append(r.Photos, models.ReviewPhoto{ReviewID: r.ID, Path: p})
+			}
+			if err := tx.Create(&r.Photos).Error; err != nil {
+				return err
+			}
+		}
+		if err := models.RecountRating(tx, r.ProductID); err != nil {
+			return err
+		}
+		review = r
+		if !created {
+			return nil
+		}
+		return models.Notify(tx, models.Notification{
+			UserID:  item.SellerID,
+			Kind:    models.NotifyReview,
+			Subject: fmt.Sprintf("New %d-star review: %s", r.Rating, item.Title),
+			Body:    r.Body,
+			Link:    "/seller/reviews",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Reply — ответ продавца на отзыв о его товаре; повторный ответ заменяет прежний
+func Reply(db *gorm.DB, reviewID, sellerID uint, text string) error {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > MaxLength {
+		return fmt.Errorf("%w: up to %d characters", ErrInvalid, MaxLength)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var r models.Review
+		if err := tx.First(&r, "id = ? AND seller_id = ?", reviewID, sellerID).Error; err != nil {
+			return err
+		}
+		var at *time.Time
+		if text != "" {
+			now := time.Now()
+			at = &now
+		}
+		if err := tx.Model(&r).Updates(map[string]any{"reply": text, "replied_at": at}).Error; err != nil {
+			return err
+		}
+		if text == "" || r.Status != models.ReviewPublished {
+			return nil
+		}
+		return models.Notify(tx, models.Notification{
+			UserID:  r.BuyerID,
+			Kind:    models.NotifyReview,
+			Subject: "The seller replied to your review",
+			Body:    text,
+			Link:    fmt.Sprintf("/product/%d", r.ProductID),
+		})
+	})
+}
+
+// Moderate скрывает отзыв (с причиной для автора) или возвращает его; рейтинг товара пересчитывается
+func Moderate(db *gorm.DB, reviewID, moderatorID uint, hide bool, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if hide && reason == "" {
+		return fmt.Errorf("%w: give a reason to hide the review", ErrInvalid)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var r models.Review
+		if err := tx.First(&r, reviewID).Error; err != nil {
+			return err
+		}
+		status := models.ReviewPublished
+		if hide {
+			status = models.ReviewHidden
+		} else {
+			reason = ""
+		}
+		err := tx.Model(&r).Updates(map[string]any{
+			"status": status, "hidden_reason": reason, "moderated_by": moderatorID, "moderated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := models.RecountRating(tx, r.ProductID); err != nil {
+			return err
+		}
+		if !hide {
+			return nil
+		}
+		return models.Notify(tx, models.Notification{
+			UserID:  r.BuyerID,
+			Kind:    models.NotifyReview,
+			Subject: "Your review was hidden",
+			Body:    "Your review was hidden by a moderator: " + reason,
+			Link:    fmt.Sprintf("/product/%d", r.ProductID),
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package reviews
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	models "marketplace/internal/models"
+)
+
+// DefaultShipWithin — за сколько после заказа продавец должен отправить свою часть, чтобы это считалось в срок
+const DefaultShipWithin = 3 * 24 * time.Hour
+
+// RatingPeriod — за какой срок считается репутация продавца
+const RatingPeriod = 365 * 24 * time.Hour
+
+// FeedbackInput — оценка продавца из формы
+type FeedbackInput struct {
+	Shipping      int
+	Communication int
+	Accuracy      int
+	Comment       string
+}
+
+func (in *FeedbackInput) validate() error {
+	in.Comment = strings.TrimSpace(in.Comment)
+	for _, v := range []int{in.Shipping, in.Communication, in.Accuracy} {
+		if v < 1 || v > 5 {
+			return fmt.Errorf("%w: rate each item 1 to 5 stars", ErrInvalid)
+		}
+	}
+	if len([]rune(in.Comment)) > MaxLength {
+		return fmt.Errorf("%w: up to %d characters", ErrInvalid, MaxLength)
+	}
+	return nil
+}
+
+// FeedbackOf — оценка продавца по отправлению; nil, если её нет
+func FeedbackOf(db *gorm.DB, shipmentID uint) (*models.SellerFeedback, error) {
+	var f models.SellerFeedback
+	err := db.First(&f, "shipment_id = ?", shipmentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SaveFeedback оставляет или правит оценку продавца покупателем. Оценить можно полученную часть заказа,
+// править — EditWindow после создания. Репутацию пересчитывает RecountSellers.
+func SaveFeedback(db *gorm.DB, buyerID uint, s models.Shipment, in FeedbackInput) (*models.SellerFeedback, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	if s.Status != models.ShipmentDelivered {
+		return nil, ErrNotDelivered
+	}
+	var feedback *models.SellerFeedback
+	err := db.Transaction(func(tx *gorm.DB) error {
+		f, err := FeedbackOf(tx, s.ID)
+		if err != nil {
+			return err
+		}
+		created := f == nil
+		if created {
+			f = &models.SellerFeedback{ShipmentID: s.ID, OrderID: s.OrderID, SellerID: s.SellerID, BuyerID: buyerID}
+		} else if time.Since(f.CreatedAt) > EditWindow {
+			return ErrLocked
+		}
+		f.Shipping, f.Communication, f.Accuracy, f.Comment = in.Shipping, in.Communication, in.Accuracy, in.Comment
+		if err := tx.Save(f).Error; err != nil {
+			return err
+		}
+		feedback = f
+		if !created {
+			return nil
+		}
+		return models.Notify(tx, models.Notification{
+			UserID:  s.SellerID,
+			Kind:    models.NotifyReview,
+			Subject: fmt.Sprintf("Order #%d: buyer rated you %.1f", s.OrderID, f.Score()),
+			Body:    f.Comment,
+			Link:    "/seller/reviews",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// RecountSellers пересчитывает seller_ratings за RatingPeriod до now: средние оценки покупателей,
+// долю отправленных в срок (shipWithin после заказа) и долю отменённых (ShipmentCancelled — все деньги
+// вернули до отправки). Отправления, перенесённые из старых заказов (OpenShipments),
+// не учитываются: даты отправки у них нет. Возвращает число продавцов.
+func RecountSellers(db *gorm.DB, shipWithin time.Duration, now time.Time) (int, error) {
+	since := now.Add(-RatingPeriod)
+	var feedback []struct {
+		SellerID                          uint
+		N                                 int
+		Shipping, Communication, Accuracy float64
+	}
+	err := db.Model(&models.SellerFeedback{}).
+		Select("seller_id, COUNT(*) AS n, AVG(shipping) AS shipping, AVG(communication) AS communication, AVG(accuracy) AS accuracy").
+		Where("created_at >= ?", since).
+		Group("seller_id").
+		Scan(&feedback).Error
+	if err != nil {
+		return 0, err
+	}
+	var shipments []struct {
+		SellerID                    uint
+		Total, Cancelled, Due, Sent int
+	}
+	err = db.Raw(`
+		WITH s AS (
+			SELECT s.seller_id, s.shipped_at, o.created_at AS ordered_at, s.status = ? AS cancelled
+			FROM shipments s
+			JOIN orders o ON o.id = s.order_id
+			WHERE o.created_at >= ? AND NOT (s.status = ? AND s.shipped_at IS NULL)
+		)
+		SELECT seller_id,
+		       COUNT(*) AS total,
+		       COUNT(*) FILTER (WHERE cancelled) AS cancelled,
+		       COUNT(*) FILTER (WHERE NOT cancelled AND (shipped_at IS NOT NULL OR ordered_at < ?)) AS due,
+		       COUNT(*) FILTER (WHERE shipped_at <= ordered_at + ? * INTERVAL '1 second') AS sent
+		FROM s
+		GROUP BY seller_id`,
+		models.ShipmentCancelled, since, models.ShipmentDelivered, now.Add(-shipWithin), shipWithin.Seconds()).
+		Scan(&shipments).Error
+	if err != nil {
+		return 0, err
+	}
+
+	ratings := map[uint]*models.SellerRating{}
+	rating := func(id uint) *models.SellerRating {
+		if ratings[id] == nil {
+			ratings[id] = &models.SellerRating{SellerID: id, UpdatedAt: now}
+		}
+		return ratings[id]
+	}
+	for _, f := range feedback {
+		r := rating(f.SellerID)
+		r.FeedbackCount = f.N
+		r.Shipping, r.Communication, r.Accuracy = f.Shipping, f.Communication, f.Accuracy
+		r.Score = (f.Shipping + f.Communication + f.Accuracy) / 3
+	}
+	for _, s := range shipments {
+		r := rating(s.SellerID)
+		r.Shipments, r.Due = s.Total, s.Due
+		if s.Due > 0 {
+			r.OnTime = float64(s.Sent) / float64(s.Due)
+		}
+		if s.Total > 0 {
+			r.Cancelled = float64(s.Cancelled) / float64(s.Total)
+		}
+	}
+	list := make([]models.SellerRating, 0, len(ratings))
+	for _, r := range ratings {
+		list = append(list, *r)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.SellerRating{}).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(list, 500).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
@@ -0,0 +1,143 @@
+package uploads
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Dir — папка на диске, куда сохраняются загруженные картинки
+	Dir = "uploads"
+	// URLPrefix — префикс, под которым файлы из Dir раздаются наружу
+	URLPrefix = "/uploads/"
+
+	// DefaultGrace — сколько живёт файл без ссылок, прежде чем GC его удалит
+	DefaultGrace = 24 * time.Hour
+	// DefaultHistory — сколько ревизии товара держат его прежние картинки
+	DefaultHistory = 90 * 24 * time.Hour
+)
+
+// Source — колонка, в которой хранятся ссылки вида "/uploads/<name>"
+type Source struct {
+	Table  string
+	Column string
+	Since  string // колонка времени строки: ссылки старше Collector.History файл не держат; "" — держат всегда
+}
+
+// Sources — все места, где могут лежать ссылки на файлы из Dir.
+// Новые таблицы с картинками нужно добавлять сюда, иначе GC удалит их файлы.
+var Sources = []Source{
+	{Table: "products", Column: "image_path"},
+	{Table: "product_revisions", Column: "snapshot->>'image_path'", Since: "created_at"}, // для отката, в пределах History
+	{Table: "seller_profiles", Column: "logo_path"},
+	{Table: "seller_profiles", Column: "banner_path"},
+	{Table: "dispute_photos", Column: "path"},
+	{Table: "review_photos", Column: "path"},
+}
+
+// Report — итог одного прохода GC
+type Report struct {
+	Scanned int      // сколько файлов просмотрено
+	Kept    int      // на сколько есть ссылки или они моложе grace
+	Removed []string // имена удалённых (или кандидатов при DryRun)
+	Bytes   int64    // сколько байт освобождено
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("scanned=%d kept=%d removed=%d reclaimed=%d bytes",
+		r.Scanned, r.Kept, len(r.Removed), r.Bytes)
+}
+
+// Collector ищет в Dir файлы без ссылок из Sources и удаляет их.
+type Collector struct {
+	DB     *gorm.DB
+	Dir    string
+	Grace  time.Duration // файлы моложе grace не трогаем: форма могла ещё не сохранить товар
+	DryRun bool          // только посчитать, ничего не удалять
+
+	// History — сколько хранить картинки из истории правок (0 — всегда). Иначе каждая замена
+	// картинки навсегда оставляла бы старый файл на диске; цена — откат к ревизии старше History
+	// вернёт поля товара, но не картинку (см. Exists).
+	History time.Duration
+}
+
+// referenced собирает имена файлов, на которые есть ссылки.
+// Таблицы читаются без soft-delete scope — картинки удалённых товаров нужны для восстановления.
+func (c *Collector) referenced() (map[string]bool, error) {
+	refs := map[string]bool{}
+	for _, s := range Sources {
+		var paths []string
+		q := c.DB.Table(s.Table).Where(s.Column + " <> ''")
+		if s.Since != "" && c.History > 0 {
+			q = q.Where(s.Since+" >= ?", time.Now().Add(-c.History))
+		}
+		err := q.Pluck(s.Column, &paths).Error
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", s.Table, s.Column, err)
+		}
+		for _, p := range paths {
+			if name, ok := strings.CutPrefix(p, URLPrefix); ok {
+				refs[name] = true
+			}
+		}
+	}
+	return refs, nil
+}
+
+// Exists — лежит ли в Dir файл по ссылке "/uploads/<name>"
+func Exists(path string) bool {
+	name, ok := strings.CutPrefix(path, URLPrefix)
+	if !ok || name == "" || strings.ContainsAny(name, `/\`) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(Dir, name))
+	return err == nil
+}
+
+// Run делает один проход GC.
+func (c *Collector) Run() (Report, error) {
+	var rep Report
+	dir := c.Dir
+	if dir == "" {
+		dir = Dir
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return rep, nil
+	}
+	if err != nil {
+		return rep, err
+	}
+	refs, err := c.referenced()
+	if err != nil {
+		return rep, err
+	}
+	cutoff := time.Now().Add(-c.Grace)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // файл успели удалить
+		}
+		rep.Scanned++
+		if refs[e.Name()] || info.ModTime().After(cutoff) {
+			rep.Kept++
+			continue
+		}
+		if !c.DryRun {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return rep, err
+			}
+		}
+		rep.Removed = append(rep.Removed, e.Name())
+		rep.Bytes += info.Size()
+	}
+	return rep, nil
+}
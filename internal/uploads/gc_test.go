@@ -0,0 +1,85 @@
+package uploads
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	models "marketplace/internal/models"
+)
+
+// Заменённая картинка держится ревизией, пока та моложе History, а потом её убирает GC.
+// Нужен Postgres (TEST_DB_DSN): ссылки из ревизий читаются из jsonb.
+func TestCollectorHistoryDB(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.Product{}, &models.ProductRevision{}, &models.SellerProfile{},
+		&models.DisputePhoto{}, &models.ReviewPhoto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+
+	dir := t.TempDir()
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	for _, name := range []string{"current.jpg", "replaced-long-ago.jpg", "replaced-recently.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("img"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := models.Product{SellerID: 900001, Title: "Kettle", PriceCents: 100, ImagePath: URLPrefix + "current.jpg"}
+	if err := tx.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, rev := range []models.ProductRevision{
+		{CreatedAt: old, ProductID: p.ID, UserID: 1, Action: models.RevisionCreate,
+			Snapshot: models.ProductSnapshot{ImagePath: URLPrefix + "replaced-long-ago.jpg"}},
+		{CreatedAt: time.Now().Add(-24 * time.Hour), ProductID: p.ID, UserID: 1, Action: models.RevisionUpdate,
+			Snapshot: models.ProductSnapshot{ImagePath: URLPrefix + "replaced-recently.jpg"}},
+	} {
+		if err := tx.Create(&rev).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// без History ревизии держат все картинки
+	gc := Collector{DB: tx, Dir: dir, Grace: time.Hour, DryRun: true}
+	rep, err := gc.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Removed) != 0 {
+		t.Errorf("without History removed %v", rep.Removed)
+	}
+
+	gc.History, gc.DryRun = 90*24*time.Hour, false
+	if rep, err = gc.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rep.Removed, []string{"replaced-long-ago.jpg"}) {
+		t.Errorf("removed %v, want the image replaced long ago", rep.Removed)
+	}
+	for name, want := range map[string]bool{"current.jpg": true, "replaced-recently.jpg": true, "replaced-long-ago.jpg": false} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+}